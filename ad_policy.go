@@ -0,0 +1,377 @@
+package combo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrAdCapReached 表示广告激励达到了 AdPolicy 的频次限制。
+var ErrAdCapReached = errors.New("ad cap reached")
+
+// AdCapReason 是广告激励被频次限制拒绝的原因。
+type AdCapReason string
+
+const (
+	// 用户当天在该广告位观看激励广告的次数达到上限。
+	AdCap_UserDailyLimit AdCapReason = "user_daily_limit"
+
+	// 距离用户上次在该广告位观看激励广告的时间未超过冷却时间。
+	AdCap_Cooldown AdCapReason = "cooldown"
+
+	// 所有用户当天在该广告位观看激励广告的总次数达到上限。
+	AdCap_GlobalDailyLimit AdCapReason = "global_daily_limit"
+)
+
+// AdCapDecision 是 AdPolicy 对一次广告激励的判定结果。
+type AdCapDecision struct {
+	// 是否允许发放激励。
+	Allowed bool `json:"allowed"`
+
+	// 不允许发放激励的原因。仅在 Allowed 为 false 时有值。
+	Reason AdCapReason `json:"reason,omitempty"`
+
+	// 距离下次允许发放激励的时间，例如冷却剩余时间，或者距离次日零点的时间。仅在 Allowed 为 false 时有值。
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
+}
+
+// AdCapError 表示广告激励被 AdPolicy 拒绝，可以被 errors.Is 识别为 ErrAdCapReached。
+type AdCapError struct {
+	// AdPolicy 的判定结果。
+	Decision AdCapDecision
+
+	// 为 true 表示这是之前兑换时缓存的结果。
+	Replayed bool
+}
+
+// Error implements error.
+func (e *AdCapError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrAdCapReached, e.Decision.Reason)
+}
+
+// Unwrap 返回 ErrAdCapReached。
+func (e *AdCapError) Unwrap() error {
+	return ErrAdCapReached
+}
+
+// AdPlacementLimits 是单个广告位的频次限制。值为 0 表示不限制。
+type AdPlacementLimits struct {
+	// 每个用户 (ComboId) 每天最多获得激励的次数。
+	UserDailyLimit int
+
+	// 同一个用户两次获得激励之间的最短间隔。
+	Cooldown time.Duration
+
+	// 所有用户每天最多获得激励的总次数。
+	GlobalDailyLimit int
+}
+
+// AdPolicyConfig 包含了创建 AdPolicy 时所必需的配置项。
+type AdPolicyConfig struct {
+	Store      AdCounterStore               // 计数器的存储。实现可以是 Redis 或 Memory，也可以自行实现 AdCounterStore
+	Placements map[string]AdPlacementLimits // 每个广告位的频次限制，key 为广告位 ID (PlacementId)。没有配置的广告位不做限制
+	Location   *time.Location               // 每日限制重置的时区，即在该时区的零点重置。如果不指定，则默认为北京时间 (UTC+8)
+	Prefix     string                       // 计数器 key 的前缀，如果不指定，则默认为 "ad_cap:"
+	Clock      Clock                        // 用于获取当前时间，如果不指定，则默认为 SystemClock
+}
+
+// AdPolicy 用于限制激励广告的发放频次。
+//
+// AdPolicy 可以单独使用，也可以通过 AdRewardRedeemerConfig.Policy 集成到 AdRewardRedeemer 中。
+type AdPolicy struct {
+	store      AdCounterStore
+	placements map[string]AdPlacementLimits
+	location   *time.Location
+	prefix     string
+	clock      Clock
+}
+
+// NewAdPolicy 创建一个 AdPolicy。
+func NewAdPolicy(cfg AdPolicyConfig) *AdPolicy {
+	if cfg.Store == nil {
+		panic("missing required cfg.Store")
+	}
+	if cfg.Location == nil {
+		cfg.Location = chinaTime
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "ad_cap:"
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = SystemClock
+	}
+	return &AdPolicy{
+		store:      cfg.Store,
+		placements: cfg.Placements,
+		location:   cfg.Location,
+		prefix:     cfg.Prefix,
+		clock:      cfg.Clock,
+	}
+}
+
+// Reserve 判定是否允许为 ad 发放激励。如果允许，则原子性地占用一次频次额度。
+//
+// Reserve 应当在发放激励之前调用，并且同一次广告播放只调用一次。
+// 如果被拒绝，返回的 AdCapDecision.Allowed 为 false，此时不会占用任何额度。
+func (p *AdPolicy) Reserve(ctx context.Context, ad *AdPayload) (AdCapDecision, error) {
+	limits, ok := p.placements[ad.PlacementId]
+	if !ok {
+		return AdCapDecision{Allowed: true}, nil
+	}
+	now := p.clock.Now().In(p.location)
+	year, month, day := now.Date()
+	nextDay := time.Date(year, month, day+1, 0, 0, 0, 0, p.location)
+	untilNextDay := nextDay.Sub(now)
+	today := now.Format("20060102")
+	// 计数器在次日零点之后还会保留一段时间，以免不同服务器之间的时间误差导致计数器提前过期。
+	counterTTL := untilNextDay + time.Hour
+
+	var rollbacks []func()
+	rollback := func() {
+		for i := len(rollbacks) - 1; i >= 0; i-- {
+			rollbacks[i]()
+		}
+	}
+
+	if limits.Cooldown > 0 {
+		key := p.prefix + "cooldown:" + ad.PlacementId + ":" + ad.ComboId
+		acquired, remaining, err := p.store.Acquire(ctx, key, limits.Cooldown)
+		if err != nil {
+			return AdCapDecision{}, err
+		}
+		if !acquired {
+			return AdCapDecision{Reason: AdCap_Cooldown, RetryAfter: remaining}, nil
+		}
+		rollbacks = append(rollbacks, func() { _ = p.store.Release(ctx, key) })
+	}
+
+	if limits.UserDailyLimit > 0 {
+		key := p.prefix + "user:" + ad.PlacementId + ":" + ad.ComboId + ":" + today
+		count, err := p.store.Incr(ctx, key, counterTTL)
+		if err != nil {
+			rollback()
+			return AdCapDecision{}, err
+		}
+		rollbacks = append(rollbacks, func() { _ = p.store.Decr(ctx, key) })
+		if count > int64(limits.UserDailyLimit) {
+			rollback()
+			return AdCapDecision{Reason: AdCap_UserDailyLimit, RetryAfter: untilNextDay}, nil
+		}
+	}
+
+	if limits.GlobalDailyLimit > 0 {
+		key := p.prefix + "global:" + ad.PlacementId + ":" + today
+		count, err := p.store.Incr(ctx, key, counterTTL)
+		if err != nil {
+			rollback()
+			return AdCapDecision{}, err
+		}
+		rollbacks = append(rollbacks, func() { _ = p.store.Decr(ctx, key) })
+		if count > int64(limits.GlobalDailyLimit) {
+			rollback()
+			return AdCapDecision{Reason: AdCap_GlobalDailyLimit, RetryAfter: untilNextDay}, nil
+		}
+	}
+
+	return AdCapDecision{Allowed: true}, nil
+}
+
+// AdCounterStore 是一个用于存储激励广告频次计数的接口。
+//
+// Combo SDK 内置了 Redis 和 Memory 两种实现，可分别通过 NewMemoryAdCounterStore() 和 NewRedisAdCounterStore() 创建。
+//
+// 游戏侧也可以选择自行实现 AdCounterStore 接口。
+type AdCounterStore interface {
+	// Incr 用于原子性地将 key 的计数加 1，并返回加 1 之后的值。
+	// 如果 key 不存在，则从 0 开始计数，并且 key 在 ttl 之后过期。
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+
+	// Decr 用于原子性地将 key 的计数减 1，用于回滚 Incr。如果 key 已经不存在（例如已过期），则忽略。
+	Decr(ctx context.Context, key string) error
+
+	// Acquire 用于原子性地占用 key，key 在 ttl 之后过期。
+	// 如果 key 不存在，则占用 key 并返回 true。如果 key 已存在，则返回 false 以及 key 的剩余过期时间。
+	Acquire(ctx context.Context, key string, ttl time.Duration) (bool, time.Duration, error)
+
+	// Release 用于释放 Acquire 占用的 key，用于回滚 Acquire。
+	Release(ctx context.Context, key string) error
+}
+
+// NewMemoryAdCounterStore 创建一个基于 Memory 的 AdCounterStore 实现。
+//
+// 注意：该实现仅用于开发调试，不适合生产环境。
+//
+// 数据仅在内存中存储，重启服务后数据会丢失，并且无法在多个游戏服务实例之间共享。
+func NewMemoryAdCounterStore(options ...MemoryStoreOption) AdCounterStore {
+	return &memoryAdCounterStore{
+		clock:   newMemoryStoreOptions(options).clock,
+		entries: make(map[string]*memoryAdCounter),
+	}
+}
+
+// NewRedisAdCounterStore 创建一个基于 Redis 的 AdCounterStore 实现。
+//
+// 数据会存储在 Redis 中，可以在多个游戏服务实例之间共享，并且到期自动清理。推荐生产环境使用。
+//
+// 注意：本实现需要 Redis >= 7.0
+func NewRedisAdCounterStore(cfg RedisAdCounterStoreConfig) AdCounterStore {
+	if cfg.Client == nil {
+		panic("missing required cfg.Client")
+	}
+	return &redisAdCounterStore{
+		client: cfg.Client,
+		prefix: cfg.Prefix,
+	}
+}
+
+// RedisAdCounterStoreConfig 包含了创建基于 Redis 的 AdCounterStore 时所必需的配置项。
+type RedisAdCounterStoreConfig struct {
+	Client redis.Cmdable // Redis 客户端。这里不假设 Redis 的运维部署方式。可以是 redis.Client 或者 redis.ClusterClient，由游戏侧自行创建和配置。
+	Prefix string        // Key 的前缀，如果不指定，则默认为空字符串。
+}
+
+type memoryAdCounter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// memoryAdCounterStoreSweepInterval 是 memoryAdCounterStore 清理过期数据的最小时间间隔。
+const memoryAdCounterStoreSweepInterval = time.Minute
+
+type memoryAdCounterStore struct {
+	mu        sync.Mutex
+	clock     Clock
+	entries   map[string]*memoryAdCounter
+	lastSweep time.Time
+}
+
+// sweep 清理过期的计数器，两次清理之间至少间隔 memoryAdCounterStoreSweepInterval。调用方必须持有锁。
+func (s *memoryAdCounterStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memoryAdCounterStoreSweepInterval {
+		return
+	}
+	for k, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, k)
+		}
+	}
+	s.lastSweep = now
+}
+
+// get 返回未过期的计数器，过期的计数器会被删除。调用方必须持有锁。
+func (s *memoryAdCounterStore) get(key string, now time.Time) *memoryAdCounter {
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if !now.Before(entry.expiresAt) {
+		delete(s.entries, key)
+		return nil
+	}
+	return entry
+}
+
+// Incr implements AdCounterStore.
+func (s *memoryAdCounterStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	s.sweep(now)
+	entry := s.get(key, now)
+	if entry == nil {
+		entry = &memoryAdCounter{expiresAt: now.Add(ttl)}
+		s.entries[key] = entry
+	}
+	entry.count++
+	return entry.count, nil
+}
+
+// Decr implements AdCounterStore.
+func (s *memoryAdCounterStore) Decr(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry := s.get(key, s.clock.Now()); entry != nil {
+		entry.count--
+	}
+	return nil
+}
+
+// Acquire implements AdCounterStore.
+func (s *memoryAdCounterStore) Acquire(ctx context.Context, key string, ttl time.Duration) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	s.sweep(now)
+	if entry := s.get(key, now); entry != nil {
+		return false, entry.expiresAt.Sub(now), nil
+	}
+	s.entries[key] = &memoryAdCounter{count: 1, expiresAt: now.Add(ttl)}
+	return true, 0, nil
+}
+
+// Release implements AdCounterStore.
+func (s *memoryAdCounterStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// 仅在 key 存在时才减 1，以免 key 在 Incr 和回滚之间过期时，DECR 创建一个没有过期时间的 -1。
+var adCounterDecrScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("DECR", KEYS[1])
+end
+return 0
+`)
+
+type redisAdCounterStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// Incr implements AdCounterStore.
+func (s *redisAdCounterStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, s.prefix+key)
+		// Note: EXPIRE 的 NX 选项需要 Redis >= 7.0，仅在 key 没有过期时间时才设置，即只在首次计数时设置。
+		pipe.ExpireNX(ctx, s.prefix+key, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// Decr implements AdCounterStore.
+func (s *redisAdCounterStore) Decr(ctx context.Context, key string) error {
+	return adCounterDecrScript.Run(ctx, s.client, []string{s.prefix + key}).Err()
+}
+
+// Acquire implements AdCounterStore.
+func (s *redisAdCounterStore) Acquire(ctx context.Context, key string, ttl time.Duration) (bool, time.Duration, error) {
+	acquired, err := s.client.SetNX(ctx, s.prefix+key, 1, ttl).Result()
+	if err != nil || acquired {
+		return acquired, 0, err
+	}
+	remaining, err := s.client.PTTL(ctx, s.prefix+key).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	if remaining < 0 {
+		// key 恰好在 SetNX 和 PTTL 之间过期了，此时认为冷却已经结束，由调用方稍后重试。
+		remaining = 0
+	}
+	return false, remaining, nil
+}
+
+// Release implements AdCounterStore.
+func (s *redisAdCounterStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
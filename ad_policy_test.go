@@ -0,0 +1,260 @@
+package combo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestAdPolicy(store AdCounterStore, now *time.Time, limits AdPlacementLimits) *AdPolicy {
+	return NewAdPolicy(AdPolicyConfig{
+		Store:      store,
+		Placements: map[string]AdPlacementLimits{"placement_001": limits},
+		Clock:      ClockFunc(func() time.Time { return *now }),
+	})
+}
+
+func testAd(comboId string) *AdPayload {
+	return &AdPayload{ComboId: comboId, PlacementId: "placement_001", ImpressionId: "impression_001"}
+}
+
+func TestAdPolicyUserDailyLimit(t *testing.T) {
+	// 2024-01-15 23:00 in Beijing time.
+	now := time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC)
+	policy := newTestAdPolicy(NewMemoryAdCounterStore(), &now, AdPlacementLimits{UserDailyLimit: 2})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if d, err := policy.Reserve(ctx, testAd("combo_1")); err != nil || !d.Allowed {
+			t.Fatalf("expected view %d to be allowed, got %+v, err=%v", i, d, err)
+		}
+	}
+	d, err := policy.Reserve(ctx, testAd("combo_1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed || d.Reason != AdCap_UserDailyLimit || d.RetryAfter != time.Hour {
+		t.Fatalf("expected user daily limit with 1h retry, got %+v", d)
+	}
+	if d, _ := policy.Reserve(ctx, testAd("combo_2")); !d.Allowed {
+		t.Fatalf("expected other users to be unaffected, got %+v", d)
+	}
+
+	// The limit resets at midnight in the configured location.
+	now = now.Add(time.Hour)
+	if d, _ := policy.Reserve(ctx, testAd("combo_1")); !d.Allowed {
+		t.Fatalf("expected limit to reset on the next day, got %+v", d)
+	}
+}
+
+func TestAdPolicyLocation(t *testing.T) {
+	now := time.Date(2024, 1, 15, 23, 30, 0, 0, time.UTC)
+	policy := NewAdPolicy(AdPolicyConfig{
+		Store:      NewMemoryAdCounterStore(),
+		Placements: map[string]AdPlacementLimits{"placement_001": {UserDailyLimit: 1}},
+		Location:   time.UTC,
+		Clock:      ClockFunc(func() time.Time { return now }),
+	})
+	ctx := context.Background()
+	_, _ = policy.Reserve(ctx, testAd("combo_1"))
+	d, _ := policy.Reserve(ctx, testAd("combo_1"))
+	if d.Allowed || d.RetryAfter != 30*time.Minute {
+		t.Fatalf("expected retry at UTC midnight, got %+v", d)
+	}
+}
+
+func TestAdPolicyCooldown(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	policy := newTestAdPolicy(NewMemoryAdCounterStore(), &now, AdPlacementLimits{Cooldown: time.Minute, UserDailyLimit: 10})
+	ctx := context.Background()
+
+	if d, _ := policy.Reserve(ctx, testAd("combo_1")); !d.Allowed {
+		t.Fatalf("expected first view to be allowed, got %+v", d)
+	}
+	d, _ := policy.Reserve(ctx, testAd("combo_1"))
+	if d.Allowed || d.Reason != AdCap_Cooldown || d.RetryAfter <= 0 || d.RetryAfter > time.Minute {
+		t.Fatalf("expected cooldown, got %+v", d)
+	}
+}
+
+func TestAdPolicyGlobalDailyLimitRollsBack(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryAdCounterStore()
+	policy := newTestAdPolicy(store, &now, AdPlacementLimits{Cooldown: time.Minute, UserDailyLimit: 1, GlobalDailyLimit: 1})
+	ctx := context.Background()
+
+	if d, _ := policy.Reserve(ctx, testAd("combo_1")); !d.Allowed {
+		t.Fatalf("expected first view to be allowed, got %+v", d)
+	}
+	d, _ := policy.Reserve(ctx, testAd("combo_2"))
+	if d.Allowed || d.Reason != AdCap_GlobalDailyLimit {
+		t.Fatalf("expected global daily limit, got %+v", d)
+	}
+
+	// The rejected reservation must not consume the user's quota or start a cooldown.
+	policy.placements["placement_001"] = AdPlacementLimits{Cooldown: time.Minute, UserDailyLimit: 1, GlobalDailyLimit: 2}
+	if d, _ := policy.Reserve(ctx, testAd("combo_2")); !d.Allowed {
+		t.Fatalf("expected rolled back reservation to be retried, got %+v", d)
+	}
+}
+
+func TestAdPolicyUnknownPlacement(t *testing.T) {
+	now := time.Now()
+	policy := newTestAdPolicy(NewMemoryAdCounterStore(), &now, AdPlacementLimits{UserDailyLimit: 1})
+	ad := &AdPayload{ComboId: "combo_1", PlacementId: "other"}
+	for i := 0; i < 3; i++ {
+		if d, _ := policy.Reserve(context.Background(), ad); !d.Allowed {
+			t.Fatalf("expected placement without limits to be allowed, got %+v", d)
+		}
+	}
+}
+
+func TestRedisAdCounterStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := NewRedisAdCounterStore(RedisAdCounterStoreConfig{Client: client, Prefix: "game:"})
+	ctx := context.Background()
+
+	for want := int64(1); want <= 2; want++ {
+		if got, err := store.Incr(ctx, "counter", time.Hour); err != nil || got != want {
+			t.Fatalf("expected %d, got %d, err=%v", want, got, err)
+		}
+	}
+	if ttl := mr.TTL("game:counter"); ttl != time.Hour {
+		t.Fatalf("expected ttl to be set on first Incr, got %v", ttl)
+	}
+	_ = store.Decr(ctx, "counter")
+	if v, _ := mr.Get("game:counter"); v != "1" {
+		t.Fatalf("expected counter to be 1 after Decr, got %s", v)
+	}
+
+	// Rolling back a counter that has already expired must not leave a key without a ttl behind.
+	mr.FastForward(time.Hour)
+	if err := store.Decr(ctx, "counter"); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists("game:counter") {
+		t.Fatal("expected Decr not to recreate an expired counter")
+	}
+
+	if ok, _, err := store.Acquire(ctx, "lock", time.Minute); err != nil || !ok {
+		t.Fatalf("expected first Acquire to succeed, err=%v", err)
+	}
+	ok, remaining, err := store.Acquire(ctx, "lock", time.Minute)
+	if err != nil || ok || remaining != time.Minute {
+		t.Fatalf("expected second Acquire to fail with remaining ttl, got ok=%v remaining=%v err=%v", ok, remaining, err)
+	}
+	_ = store.Release(ctx, "lock")
+	if ok, _, _ := store.Acquire(ctx, "lock", time.Minute); !ok {
+		t.Fatal("expected Acquire to succeed after Release")
+	}
+}
+
+func TestMemoryAdCounterStoreSweep(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryAdCounterStore(WithMemoryStoreClock(ClockFunc(func() time.Time { return now }))).(*memoryAdCounterStore)
+	ctx := context.Background()
+
+	_, _ = store.Incr(ctx, "user_1:20240115", time.Minute)
+	_, _, _ = store.Acquire(ctx, "cooldown:user_1", time.Minute)
+	_, _ = store.Incr(ctx, "global:20240115", time.Hour)
+
+	now = now.Add(2 * time.Minute)
+	_, _ = store.Incr(ctx, "user_2:20240115", time.Minute)
+	if len(store.entries) != 2 {
+		t.Fatalf("expected expired entries to be swept, got %v", store.entries)
+	}
+}
+
+type flakyAdCounterStore struct {
+	AdCounterStore
+	fail bool
+}
+
+func (s *flakyAdCounterStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	if s.fail {
+		return 0, errors.New("store unavailable")
+	}
+	return s.AdCounterStore.Incr(ctx, key, ttl)
+}
+
+func TestAdRewardRedeemerRetriesAfterPolicyError(t *testing.T) {
+	counters := &flakyAdCounterStore{AdCounterStore: NewMemoryAdCounterStore(), fail: true}
+	calls := 0
+	redeemer := NewAdRewardRedeemer(AdRewardRedeemerConfig{
+		Verifier: newTestVerifier(t),
+		Store:    NewMemoryIdempotencyStore(),
+		Rewards: map[string]AdRewardFunc{
+			"placement_001": func(ctx context.Context, ad *AdPayload) (any, error) {
+				calls++
+				return "gold", nil
+			},
+		},
+		Policy: NewAdPolicy(AdPolicyConfig{
+			Store:      counters,
+			Placements: map[string]AdPlacementLimits{"placement_001": {UserDailyLimit: 1}},
+		}),
+	})
+	ctx := context.Background()
+	adToken := signAdToken(t, "combo_456", "placement_001", "impression_001")
+
+	_, err := redeemer.Redeem(ctx, adToken)
+	var rewardErr *AdRewardError
+	if err == nil || errors.As(err, &rewardErr) {
+		t.Fatalf("expected policy store error, got %v", err)
+	}
+	counters.fail = false
+	r, err := redeemer.Redeem(ctx, adToken)
+	if err != nil || r.Replayed {
+		t.Fatalf("expected retry to redeem the reward, got %+v, err=%v", r, err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected reward func to be called once, got %d", calls)
+	}
+}
+
+func TestAdRewardRedeemerWithPolicy(t *testing.T) {
+	calls := 0
+	redeemer := NewAdRewardRedeemer(AdRewardRedeemerConfig{
+		Verifier: newTestVerifier(t),
+		Store:    NewMemoryIdempotencyStore(),
+		Rewards: map[string]AdRewardFunc{
+			"placement_001": func(ctx context.Context, ad *AdPayload) (any, error) {
+				calls++
+				return "gold", nil
+			},
+		},
+		Policy: NewAdPolicy(AdPolicyConfig{
+			Store:      NewMemoryAdCounterStore(),
+			Placements: map[string]AdPlacementLimits{"placement_001": {UserDailyLimit: 1}},
+		}),
+	})
+	ctx := context.Background()
+
+	first := signAdToken(t, "combo_456", "placement_001", "impression_001")
+	if _, err := redeemer.Redeem(ctx, first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Replaying the same impression does not consume the cap.
+	if r, err := redeemer.Redeem(ctx, first); err != nil || !r.Replayed {
+		t.Fatalf("expected replayed redemption, got %+v, err=%v", r, err)
+	}
+
+	second := signAdToken(t, "combo_456", "placement_001", "impression_002")
+	_, err := redeemer.Redeem(ctx, second)
+	var capErr *AdCapError
+	if !errors.As(err, &capErr) || !errors.Is(err, ErrAdCapReached) || capErr.Decision.Reason != AdCap_UserDailyLimit {
+		t.Fatalf("expected AdCapError, got %v", err)
+	}
+	_, err = redeemer.Redeem(ctx, second)
+	if !errors.As(err, &capErr) || !capErr.Replayed {
+		t.Fatalf("expected replayed AdCapError, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected reward func to be called once, got %d", calls)
+	}
+}
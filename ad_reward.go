@@ -0,0 +1,255 @@
+package combo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUnknownPlacement 表示 AdToken 中的广告位 ID 没有配置对应的 AdRewardFunc。
+	ErrUnknownPlacement = errors.New("unknown placement")
+
+	// ErrAdRedemptionInProgress 表示同一次广告播放的激励正在发放中，通常是客户端并发提交了同一个 AdToken。
+	ErrAdRedemptionInProgress = errors.New("ad redemption is in progress")
+)
+
+// AdRewardFunc 用于发放一次广告播放的激励。返回值是发放的激励，会以 JSON 的格式缓存。
+//
+// 对于同一次广告播放 (ImpressionId)，AdRewardFunc 最多只会被调用一次。
+// 注意：返回 error 时，失败的结果同样会被缓存，重复兑换时直接返回该失败结果，而不会再次调用 AdRewardFunc。
+type AdRewardFunc func(ctx context.Context, ad *AdPayload) (any, error)
+
+// AdRewardError 表示 AdRewardFunc 发放激励失败。
+type AdRewardError struct {
+	// AdRewardFunc 返回的错误信息。
+	Message string
+
+	// 为 true 表示这是之前兑换时缓存的失败结果。
+	Replayed bool
+
+	err error
+}
+
+// Error implements error.
+func (e *AdRewardError) Error() string {
+	return "ad reward failed: " + e.Message
+}
+
+// Unwrap 返回 AdRewardFunc 返回的原始错误。仅在首次兑换时有值。
+func (e *AdRewardError) Unwrap() error {
+	return e.err
+}
+
+// AdRedemption 是一次广告激励兑换的结果。
+type AdRedemption struct {
+	// 广告的播放信息。
+	Ad *AdPayload
+
+	// AdRewardFunc 返回的激励，以 JSON 的格式表示。
+	Reward json.RawMessage
+
+	// 为 true 表示同一次广告播放之前已经兑换过，Reward 是缓存的结果。
+	Replayed bool
+}
+
+// AdRewardRedeemerConfig 包含了创建 AdRewardRedeemer 时所必需的配置项。
+type AdRewardRedeemerConfig struct {
+	Verifier *TokenVerifier          // 用于验证 AdToken 的 TokenVerifier
+	Store    IdempotencyStore        // 兑换记录的存储。实现可以是 Redis 或 Memory，自行实现时必须同时实现 AdRedemptionStore
+	Rewards  map[string]AdRewardFunc // 每个广告位的激励发放函数，key 为广告位 ID (PlacementId)
+	Policy   *AdPolicy               // 广告激励的频次限制，如果不指定，则不做限制
+	Prefix   string                  // 兑换记录的 key 前缀，如果不指定，则默认为 "ad_reward:"
+	Logger   *slog.Logger            // 记录日志的 logger，如果不指定，则默认会使用输出到 stderr 的 TextHandler
+}
+
+// AdRewardRedeemer 用于验证 AdToken 并发放广告激励，保证同一次广告播放的激励只发放一次。
+type AdRewardRedeemer struct {
+	verifier *TokenVerifier
+	store    AdRedemptionStore
+	rewards  map[string]AdRewardFunc
+	policy   *AdPolicy
+	prefix   string
+	logger   *slog.Logger
+}
+
+// NewAdRewardRedeemer 创建一个 AdRewardRedeemer。
+func NewAdRewardRedeemer(cfg AdRewardRedeemerConfig) *AdRewardRedeemer {
+	if cfg.Verifier == nil {
+		panic("missing required cfg.Verifier")
+	}
+	if cfg.Store == nil {
+		panic("missing required cfg.Store")
+	}
+	store, ok := cfg.Store.(AdRedemptionStore)
+	if !ok {
+		panic("cfg.Store must implement AdRedemptionStore")
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "ad_reward:"
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &AdRewardRedeemer{
+		verifier: cfg.Verifier,
+		store:    store,
+		rewards:  cfg.Rewards,
+		policy:   cfg.Policy,
+		prefix:   cfg.Prefix,
+		logger:   cfg.Logger,
+	}
+}
+
+type adRedemptionRecord struct {
+	Nonce        string          `json:"nonce"` // 这里的 nonce 是 adRedemptionRecord 的唯一标识，用于解决 Redis 的 SetNX 缺乏幂等性的问题。
+	Key          string          `json:"key"`
+	ComboId      string          `json:"combo_id"`
+	PlacementId  string          `json:"placement_id"`
+	ImpressionId string          `json:"impression_id"`
+	Done         bool            `json:"done"`
+	Reward       json.RawMessage `json:"reward"`
+	Error        *string         `json:"error"`
+	Cap          *AdCapDecision  `json:"cap"`
+}
+
+// Redeem 验证 AdToken，并调用广告位对应的 AdRewardFunc 发放激励。
+//
+// 同一次广告播放 (ImpressionId) 重复兑换时，不会再次调用 AdRewardFunc，而是返回缓存的结果，并且 AdRedemption.Replayed 为 true。
+//
+// 可能返回的错误：
+//   - AdToken 验证失败时，返回 VerifyAdToken 的错误。
+//   - 广告位没有对应的 AdRewardFunc 时，返回 ErrUnknownPlacement。
+//   - 同一次广告播放正在兑换中时，返回 ErrAdRedemptionInProgress。
+//   - 达到 AdPolicy 的频次限制时，返回 *AdCapError，此时不会调用 AdRewardFunc。
+//   - AdRewardFunc 返回错误时，返回 *AdRewardError。
+//   - 访问 AdPolicy 的 AdCounterStore 出错时，返回相应的 error。此时不会缓存兑换结果，客户端可以稍后重试。
+func (r *AdRewardRedeemer) Redeem(ctx context.Context, adToken string) (*AdRedemption, error) {
+	ad, err := r.verifier.VerifyAdToken(adToken)
+	if err != nil {
+		return nil, err
+	}
+	reward, ok := r.rewards[ad.PlacementId]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPlacement, ad.PlacementId)
+	}
+	nonce, err := uuid.NewRandom()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	record := &adRedemptionRecord{
+		Nonce:        nonce.String(),
+		Key:          r.prefix + ad.ImpressionId,
+		ComboId:      ad.ComboId,
+		PlacementId:  ad.PlacementId,
+		ImpressionId: ad.ImpressionId,
+	}
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ad redemption record: %w", err)
+	}
+	// 兑换记录至少要保留到 AdToken 过期，否则同一个 AdToken 可以在记录过期后再次兑换。
+	ttl := ad.ExpiresAt().Add(r.verifier.leeway).Sub(r.verifier.clock.Now())
+	oldRecordStr, err := r.store.SetNXWithTTL(ctx, record.Key, string(recordBytes), ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to SetNX ad redemption record: %w", err)
+	}
+	var oldRecord *adRedemptionRecord
+	if oldRecordStr != "" {
+		oldRecord = &adRedemptionRecord{}
+		if err := json.Unmarshal([]byte(oldRecordStr), oldRecord); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal old ad redemption record: %w", err)
+		}
+	}
+	// 和 idempotentGmListener 相同，Nonce 一致说明 SetNX 其实是当前 goroutine 执行成功的，应当作为首次兑换来处理。
+	if oldRecord == nil || oldRecord.Nonce == record.Nonce {
+		return r.redeem(ctx, ad, reward, record)
+	}
+	return r.previousRedemption(ad, oldRecord)
+}
+
+func (r *AdRewardRedeemer) redeem(ctx context.Context, ad *AdPayload, reward AdRewardFunc, record *adRedemptionRecord) (*AdRedemption, error) {
+	if r.policy != nil {
+		decision, err := r.policy.Reserve(ctx, ad)
+		if err != nil {
+			// 访问 AdCounterStore 出错是暂时性的，这里删除兑换记录，以便客户端稍后重试，而不是缓存失败的结果。
+			r.deleteRecord(ctx, record)
+			return nil, fmt.Errorf("failed to reserve ad cap: %w", err)
+		}
+		if !decision.Allowed {
+			record.Done = true
+			record.Cap = &decision
+			r.saveRecord(ctx, record)
+			return nil, &AdCapError{Decision: decision}
+		}
+	}
+	resp, rewardErr := reward(ctx, ad)
+	record.Done = true
+	if rewardErr != nil {
+		message := rewardErr.Error()
+		record.Error = &message
+	} else {
+		record.Reward, _ = json.Marshal(resp)
+	}
+	r.saveRecord(ctx, record)
+	if rewardErr != nil {
+		return nil, &AdRewardError{Message: *record.Error, err: rewardErr}
+	}
+	return &AdRedemption{Ad: ad, Reward: record.Reward}, nil
+}
+
+func (r *AdRewardRedeemer) saveRecord(ctx context.Context, record *adRedemptionRecord) {
+	recordBytes, _ := json.Marshal(record)
+	// 和 idempotentGmListener 相同，保存失败时仅记录日志，而不改变返回给调用方的结果。
+	if err := r.store.SetXX(ctx, record.Key, string(recordBytes)); err != nil {
+		r.logger.ErrorContext(ctx,
+			"failed to SetXX ad redemption record",
+			slog.Any("err", err),
+			slog.Group("record",
+				slog.String("nonce", record.Nonce),
+				slog.String("key", record.Key),
+				slog.String("combo_id", record.ComboId),
+				slog.String("placement_id", record.PlacementId),
+				slog.String("impression_id", record.ImpressionId),
+				slog.String("reward", string(record.Reward)),
+				slog.Any("error", record.Error),
+				slog.Any("cap", record.Cap),
+			),
+		)
+	}
+}
+
+func (r *AdRewardRedeemer) deleteRecord(ctx context.Context, record *adRedemptionRecord) {
+	// 删除失败时，记录会一直处于兑换中的状态，直到过期。这里仅记录日志，而不改变返回给调用方的结果。
+	if err := r.store.Delete(ctx, record.Key); err != nil {
+		r.logger.ErrorContext(ctx,
+			"failed to delete ad redemption record",
+			slog.Any("err", err),
+			slog.Group("record",
+				slog.String("nonce", record.Nonce),
+				slog.String("key", record.Key),
+				slog.String("combo_id", record.ComboId),
+				slog.String("placement_id", record.PlacementId),
+				slog.String("impression_id", record.ImpressionId),
+			),
+		)
+	}
+}
+
+func (r *AdRewardRedeemer) previousRedemption(ad *AdPayload, record *adRedemptionRecord) (*AdRedemption, error) {
+	if !record.Done {
+		return nil, ErrAdRedemptionInProgress
+	}
+	if record.Cap != nil {
+		return nil, &AdCapError{Decision: *record.Cap, Replayed: true}
+	}
+	if record.Error != nil {
+		return nil, &AdRewardError{Message: *record.Error, Replayed: true}
+	}
+	return &AdRedemption{Ad: ad, Reward: record.Reward, Replayed: true}, nil
+}
@@ -0,0 +1,191 @@
+package combo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signAdToken(t *testing.T, comboId, placementId, impressionId string) string {
+	t.Helper()
+	return signToken(t, &adClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(testEndpoint),
+			Subject:   comboId,
+			Audience:  jwt.ClaimStrings{string(testGameId)},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		Scope:        "ads",
+		PlacementId:  placementId,
+		ImpressionId: impressionId,
+	})
+}
+
+func newTestAdRewardRedeemer(t *testing.T, store IdempotencyStore, rewards map[string]AdRewardFunc) *AdRewardRedeemer {
+	t.Helper()
+	return NewAdRewardRedeemer(AdRewardRedeemerConfig{
+		Verifier: newTestVerifier(t),
+		Store:    store,
+		Rewards:  rewards,
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+}
+
+func TestAdRewardRedeemer(t *testing.T) {
+	calls := 0
+	redeemer := newTestAdRewardRedeemer(t, NewMemoryIdempotencyStore(), map[string]AdRewardFunc{
+		"placement_001": func(ctx context.Context, ad *AdPayload) (any, error) {
+			calls++
+			return map[string]int{"gold": 100}, nil
+		},
+	})
+	ctx := context.Background()
+	adToken := signAdToken(t, "combo_456", "placement_001", "impression_001")
+
+	first, err := redeemer.Redeem(ctx, adToken)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.Replayed || string(first.Reward) != `{"gold":100}` || first.Ad.ComboId != "combo_456" {
+		t.Fatalf("unexpected first redemption: %+v", first)
+	}
+
+	second, err := redeemer.Redeem(ctx, adToken)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !second.Replayed || string(second.Reward) != `{"gold":100}` {
+		t.Fatalf("unexpected second redemption: %+v", second)
+	}
+	if calls != 1 {
+		t.Fatalf("expected reward to be granted once, got %d", calls)
+	}
+
+	// A different impression of the same placement is rewarded again.
+	if _, err := redeemer.Redeem(ctx, signAdToken(t, "combo_456", "placement_001", "impression_002")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected reward to be granted for new impression, got %d", calls)
+	}
+}
+
+func TestAdRewardRedeemerCachesFailure(t *testing.T) {
+	errOutOfStock := errors.New("out of stock")
+	calls := 0
+	redeemer := newTestAdRewardRedeemer(t, NewMemoryIdempotencyStore(), map[string]AdRewardFunc{
+		"placement_001": func(ctx context.Context, ad *AdPayload) (any, error) {
+			calls++
+			return nil, errOutOfStock
+		},
+	})
+	adToken := signAdToken(t, "combo_456", "placement_001", "impression_001")
+
+	_, err := redeemer.Redeem(context.Background(), adToken)
+	var rewardErr *AdRewardError
+	if !errors.As(err, &rewardErr) || rewardErr.Replayed || !errors.Is(err, errOutOfStock) {
+		t.Fatalf("expected first AdRewardError, got %v", err)
+	}
+
+	_, err = redeemer.Redeem(context.Background(), adToken)
+	if !errors.As(err, &rewardErr) || !rewardErr.Replayed || rewardErr.Message != "out of stock" {
+		t.Fatalf("expected replayed AdRewardError, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected reward func to be called once, got %d", calls)
+	}
+}
+
+func TestAdRewardRedeemerInProgress(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	adToken := signAdToken(t, "combo_456", "placement_001", "impression_001")
+	var redeemer *AdRewardRedeemer
+	var nestedErr error
+	redeemer = newTestAdRewardRedeemer(t, store, map[string]AdRewardFunc{
+		"placement_001": func(ctx context.Context, ad *AdPayload) (any, error) {
+			_, nestedErr = redeemer.Redeem(ctx, adToken)
+			return "ok", nil
+		},
+	})
+	if _, err := redeemer.Redeem(context.Background(), adToken); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !errors.Is(nestedErr, ErrAdRedemptionInProgress) {
+		t.Fatalf("expected ErrAdRedemptionInProgress, got %v", nestedErr)
+	}
+}
+
+func TestAdRewardRedeemerErrors(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	redeemer := newTestAdRewardRedeemer(t, store, map[string]AdRewardFunc{})
+
+	_, err := redeemer.Redeem(context.Background(), signAdToken(t, "combo_456", "placement_404", "impression_001"))
+	if !errors.Is(err, ErrUnknownPlacement) {
+		t.Fatalf("expected ErrUnknownPlacement, got %v", err)
+	}
+	if _, err := redeemer.Redeem(context.Background(), "invalid"); err == nil {
+		t.Fatal("expected error for invalid ad token")
+	}
+	if old, _ := store.SetNX(context.Background(), "ad_reward:impression_001", "x"); old != "" {
+		t.Fatal("rejected redemptions should not be recorded")
+	}
+}
+
+func TestAdRewardRedeemerWithRedis(t *testing.T) {
+	store, mr := newTestRedisStore(t)
+	calls := 0
+	redeemer := newTestAdRewardRedeemer(t, store, map[string]AdRewardFunc{
+		"placement_001": func(ctx context.Context, ad *AdPayload) (any, error) {
+			calls++
+			return "gem", nil
+		},
+	})
+	adToken := signAdToken(t, "combo_456", "placement_001", "impression_001")
+
+	for i := 0; i < 3; i++ {
+		redemption, err := redeemer.Redeem(context.Background(), adToken)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if redemption.Replayed != (i > 0) {
+			t.Fatalf("unexpected Replayed for attempt %d", i)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected reward func to be called once, got %d", calls)
+	}
+	if !mr.Exists("ad_reward:impression_001") {
+		t.Fatal("expected redemption record to be stored")
+	}
+	// The store TTL is 10 minutes, but the record must outlive the ad token, which expires in an hour.
+	if ttl := mr.TTL("ad_reward:impression_001"); ttl < 59*time.Minute {
+		t.Fatalf("expected record ttl to cover the ad token lifetime, got %v", ttl)
+	}
+}
+
+func TestNewAdRewardRedeemerRequiresAdRedemptionStore(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected panic for store without AdRedemptionStore")
+		}
+	}()
+	NewAdRewardRedeemer(AdRewardRedeemerConfig{
+		Verifier: newTestVerifier(t),
+		Store:    struct{ IdempotencyStore }{NewMemoryIdempotencyStore()},
+	})
+}
+
+func TestNewAdRewardRedeemerPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected panic for missing store")
+		}
+	}()
+	NewAdRewardRedeemer(AdRewardRedeemerConfig{Verifier: newTestVerifier(t)})
+}
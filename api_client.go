@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -45,17 +46,43 @@ func WithHttpClient(client HttpClient) ClientOption {
 	}
 }
 
+// WithSignedHeaders 用于指定需要签名的 HTTP 请求头，例如 Content-Type。
+//
+// 指定后，Client 会使用 v2 版本的签名算法，签名会覆盖 Host 以及这里指定的请求头，并使用规范化的 query string。
+// 如果不指定，则使用 v1 版本的签名算法。
+//
+// 请求头名称不能为空，也不能是 Authorization（它的值包含签名本身，无法被签名），否则会 panic。
+func WithSignedHeaders(headers ...string) ClientOption {
+	return func(c *Client) {
+		signedHeaders := normalizeSignedHeaders(headers)
+		for _, h := range signedHeaders {
+			if h == "" || h == strings.ToLower(authorizationHeader) {
+				panic(fmt.Sprintf("invalid signed header: %q", h))
+			}
+		}
+		c.signer.signedHeaders = signedHeaders
+	}
+}
+
+// WithClockSkew 用于校正本机时间和世游服务端时间的偏差。
+//
+// Client 计算签名时使用的时间为 Config.Clock 的当前时间加上 skew。
+// 当游戏服务器的系统时间无法及时同步，导致请求因签名时间误差过大而被拒绝时，可以通过此选项进行补偿。
+// 例如本机时间比服务端慢 10 分钟，则 skew 应设置为 10 * time.Minute。
+func WithClockSkew(skew time.Duration) ClientOption {
+	return func(c *Client) {
+		c.signer.clockSkew = skew
+	}
+}
+
 // NewClient 创建一个新的 Server API 的 client。
 func NewClient(cfg Config, options ...ClientOption) (*Client, error) {
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
 	c := &Client{
-		endpoint: cfg.Endpoint,
-		signer: httpSigner{
-			game:       cfg.GameId,
-			signingKey: cfg.SecretKey,
-		},
+		endpoint:  cfg.Endpoint,
+		signer:    newHttpSigner(cfg),
 		userAgent: userAgent(cfg.GameId),
 	}
 	for _, option := range options {
@@ -123,5 +150,5 @@ func (c *Client) setRequestHeaders(req *http.Request) {
 }
 
 func (c *Client) signRequest(req *http.Request) error {
-	return c.signer.SignHttp(req, time.Now())
+	return c.signer.SignHttp(req, c.signer.signingTime())
 }
@@ -0,0 +1,72 @@
+package combo
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// AuthFailureReason 是 HTTP 请求签名验证失败的原因。
+type AuthFailureReason string
+
+const (
+	// 请求中缺少 Authorization header。
+	AuthFailure_MissingHeader AuthFailureReason = "missing_header"
+
+	// Authorization header 的格式不正确，或者签名算法、签名版本不被接受，或者 v2 签名的请求中 query string 的格式不正确。
+	AuthFailure_BadScheme AuthFailureReason = "bad_scheme"
+
+	// Authorization header 中的签名时间格式不正确。
+	AuthFailure_BadTimestamp AuthFailureReason = "bad_timestamp"
+
+	// 签名时间和当前时间的误差超出了允许的范围。通常是请求方或游戏服务器的系统时间不准确导致的。
+	AuthFailure_ClockSkew AuthFailureReason = "clock_skew"
+
+	// 签名中的 Game ID 和 Config.GameId 不一致。通常是配置错误导致的。
+	AuthFailure_WrongGame AuthFailureReason = "wrong_game"
+
+	// 签名不正确。这意味着请求不可信，或者双方使用的 Secret Key 不一致。
+	AuthFailure_BadSignature AuthFailureReason = "bad_signature"
+
+	// 签名已经被使用过，即请求被重放了。仅在开启了防重放校验时出现。
+	AuthFailure_Replayed AuthFailureReason = "replayed"
+)
+
+// AuthError 是 HTTP 请求签名验证失败时返回的错误。
+//
+// 游戏侧可使用 errors.As 来获取 AuthError，并根据 Reason 区分不同的失败原因。
+type AuthError struct {
+	// 签名验证失败的原因。
+	Reason AuthFailureReason
+
+	// 服务端计算签名时使用的 string to sign。
+	// 仅在通过 WithAuthDebug 开启调试模式，并且 Reason 为 AuthFailure_BadSignature 时才有值。
+	StringToSign string
+
+	message string
+	err     error
+}
+
+func newAuthError(reason AuthFailureReason, format string, args ...any) *AuthError {
+	return &AuthError{
+		Reason:  reason,
+		message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Error implements error.
+func (e *AuthError) Error() string {
+	if e.StringToSign != "" {
+		return e.message + ", string to sign: " + strconv.Quote(e.StringToSign)
+	}
+	return e.message
+}
+
+// Unwrap 返回导致签名验证失败的底层错误，例如 ErrReplayedRequest。
+func (e *AuthError) Unwrap() error {
+	return e.err
+}
+
+// AuthFailureFunc 在 HTTP 请求签名验证失败时被调用。
+//
+// remoteAddr 是请求方的网络地址，即 http.Request.RemoteAddr。
+type AuthFailureFunc func(err *AuthError, remoteAddr string)
@@ -0,0 +1,164 @@
+package combo
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthHttpFailureReasons(t *testing.T) {
+	signingTime := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		header     string
+		signer     *httpSigner
+		now        time.Time
+		wantReason AuthFailureReason
+	}{
+		{
+			name:       "missing header",
+			wantReason: AuthFailure_MissingHeader,
+		},
+		{
+			name:       "malformed header",
+			header:     "garbage",
+			wantReason: AuthFailure_BadScheme,
+		},
+		{
+			name:       "bad scheme",
+			header:     "Bearer Game=test_game,Timestamp=20240115T120000Z,Signature=abc",
+			wantReason: AuthFailure_BadScheme,
+		},
+		{
+			name:       "bad timestamp",
+			header:     "SEAYOO-HMAC-SHA256 Game=test_game,Timestamp=yesterday,Signature=abc",
+			wantReason: AuthFailure_BadTimestamp,
+		},
+		{
+			name:       "missing timestamp",
+			header:     "SEAYOO-HMAC-SHA256 Game=test_game,Signature=abc",
+			wantReason: AuthFailure_BadTimestamp,
+		},
+		{
+			name:       "clock skew",
+			signer:     newTestSigner(),
+			now:        signingTime.Add(time.Hour),
+			wantReason: AuthFailure_ClockSkew,
+		},
+		{
+			name:       "wrong game",
+			signer:     &httpSigner{game: "other_game", signingKey: SecretKey("sk_test_secret")},
+			wantReason: AuthFailure_WrongGame,
+		},
+		{
+			name:       "bad signature",
+			signer:     &httpSigner{game: "test_game", signingKey: SecretKey("sk_other_secret")},
+			wantReason: AuthFailure_BadSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "https://example.com/test", bytes.NewBufferString(`{}`))
+			if tt.signer != nil {
+				_ = tt.signer.SignHttp(req, signingTime)
+			} else if tt.header != "" {
+				req.Header.Set(authorizationHeader, tt.header)
+			}
+			now := tt.now
+			if now.IsZero() {
+				now = signingTime
+			}
+
+			err := newTestSigner().AuthHttp(req, now)
+			var authErr *AuthError
+			if !errors.As(err, &authErr) {
+				t.Fatalf("expected *AuthError, got %T: %v", err, err)
+			}
+			if authErr.Reason != tt.wantReason {
+				t.Fatalf("expected reason %s, got %s", tt.wantReason, authErr.Reason)
+			}
+			if authErr.StringToSign != "" {
+				t.Fatal("string to sign should only be included in debug mode")
+			}
+		})
+	}
+}
+
+func TestAuthHttpDebugStringToSign(t *testing.T) {
+	signingTime := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/test", bytes.NewBufferString(`{}`))
+	other := &httpSigner{game: "test_game", signingKey: SecretKey("sk_other_secret")}
+	_ = other.SignHttp(req, signingTime)
+
+	signer := newTestSigner()
+	signer.debug = true
+	err := signer.AuthHttp(req, signingTime)
+	var authErr *AuthError
+	if !errors.As(err, &authErr) {
+		t.Fatalf("expected *AuthError, got %T: %v", err, err)
+	}
+	want, _ := buildStringToSign(req, getTimestamp(signingTime))
+	if authErr.StringToSign != want {
+		t.Fatalf("expected string to sign %q, got %q", want, authErr.StringToSign)
+	}
+	if !strings.Contains(authErr.Error(), "string to sign") {
+		t.Fatalf("expected error message to include string to sign, got %s", authErr.Error())
+	}
+}
+
+func TestRequireSignatureOnAuthFailure(t *testing.T) {
+	var gotErr *AuthError
+	var gotAddr string
+	handler, _ := newTestSignatureHandler(t, http.NotFoundHandler(),
+		WithOnAuthFailure(func(err *AuthError, remoteAddr string) {
+			gotErr = err
+			gotAddr = remoteAddr
+		}),
+		WithAuthDebug(),
+	)
+
+	other := &httpSigner{game: testGameId, signingKey: SecretKey("sk_other_secret")}
+	req := signedRequest(t, other, time.Now(), []byte(`{}`))
+	req.RemoteAddr = "203.0.113.7:4321"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+	if gotErr == nil || gotErr.Reason != AuthFailure_BadSignature {
+		t.Fatalf("expected bad signature failure, got %v", gotErr)
+	}
+	if gotAddr != "203.0.113.7:4321" {
+		t.Fatalf("expected remote address to be reported, got %s", gotAddr)
+	}
+	if !strings.Contains(rec.Body.String(), "string to sign") {
+		t.Fatalf("expected debug response to include string to sign, got %s", rec.Body.String())
+	}
+}
+
+func TestRequireSignatureOnAuthFailureReplayed(t *testing.T) {
+	var reasons []AuthFailureReason
+	handler, signer := newTestSignatureHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithReplayStore(NewMemoryReplayStore()),
+		WithOnAuthFailure(func(err *AuthError, remoteAddr string) {
+			if !errors.Is(err, ErrReplayedRequest) {
+				t.Errorf("expected replayed error to match ErrReplayedRequest, got %v", err)
+			}
+			reasons = append(reasons, err.Reason)
+		}),
+	)
+
+	signingTime := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), signedRequest(t, signer, signingTime, []byte(`{}`)))
+	handler.ServeHTTP(httptest.NewRecorder(), signedRequest(t, signer, signingTime, []byte(`{}`)))
+
+	if len(reasons) != 1 || reasons[0] != AuthFailure_Replayed {
+		t.Fatalf("expected a single replayed failure, got %v", reasons)
+	}
+}
@@ -0,0 +1,23 @@
+package combo
+
+import "time"
+
+// Clock 用于获取当前时间。
+//
+// Combo SDK 在计算签名、验证签名的时间误差、验证 Token 的有效期时，都会通过 Clock 获取当前时间。
+// 默认使用系统时间。游戏侧可以在测试中通过 Config.Clock 指定固定的时间，
+// 并通过 WithMemoryStoreClock 让基于 Memory 的各类 Store 使用同一个 Clock。
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc 是一个函数形式的 Clock 实现。
+type ClockFunc func() time.Time
+
+// Now implements Clock.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock 是使用系统时间的 Clock 实现。
+var SystemClock Clock = ClockFunc(time.Now)
@@ -0,0 +1,156 @@
+package combo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newFrozenConfig(now time.Time) Config {
+	cfg := newTestConfig()
+	cfg.Clock = ClockFunc(func() time.Time { return now })
+	return cfg
+}
+
+func TestRequireSignatureUsesConfigClock(t *testing.T) {
+	frozen := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	handler, err := RequireSignature(newFrozenConfig(frozen), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &httpSigner{game: testGameId, signingKey: SecretKey(testSecretKey)}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest(t, signer, frozen.Add(-4*time.Minute), []byte(`{}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest(t, signer, time.Now(), []byte(`{}`)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d for request signed with wall clock, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestWithMaxClockSkew(t *testing.T) {
+	frozen := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	handler, err := RequireSignature(newFrozenConfig(frozen), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithMaxClockSkew(30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &httpSigner{game: testGameId, signingKey: SecretKey(testSecretKey)}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest(t, signer, frozen.Add(-20*time.Second), []byte(`{}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest(t, signer, frozen.Add(-time.Minute), []byte(`{}`)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestClientSignsWithConfigClock(t *testing.T) {
+	frozen := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	client, err := NewClient(newFrozenConfig(frozen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := client.newHttpRequest(context.Background(), "test-api", map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := parseAuthorizationHeader(req.Header.Get(authorizationHeader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !auth.timestamp.Equal(frozen) {
+		t.Fatalf("expected signing time %s, got %s", frozen, auth.timestamp)
+	}
+}
+
+func TestClientWithClockSkew(t *testing.T) {
+	frozen := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	client, err := NewClient(newFrozenConfig(frozen), WithClockSkew(10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := client.newHttpRequest(context.Background(), "test-api", map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := parseAuthorizationHeader(req.Header.Get(authorizationHeader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := frozen.Add(10 * time.Minute); !auth.timestamp.Equal(want) {
+		t.Fatalf("expected signing time %s, got %s", want, auth.timestamp)
+	}
+}
+
+func TestMemoryStoresUseClock(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	clock := WithMemoryStoreClock(ClockFunc(func() time.Time { return now }))
+	ctx := context.Background()
+
+	counters := NewMemoryAdCounterStore(clock)
+	if _, err := counters.Incr(ctx, "counter", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	sessions := NewMemorySessionStore(clock)
+	if _, _, err := sessions.Claim(ctx, Session{ComboId: "combo_123", DeviceId: "device_1", LoginTime: now}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	tokens := NewMemorySessionTokenStore(clock)
+	if err := tokens.Create(ctx, "sid", "t1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Minute)
+	if n, _ := counters.Incr(ctx, "counter", time.Minute); n != 1 {
+		t.Fatalf("expected counter to expire, got %d", n)
+	}
+	if current, _ := sessions.Get(ctx, "combo_123"); current != nil {
+		t.Fatalf("expected session to expire, got %+v", current)
+	}
+	if active, _ := tokens.IsActive(ctx, "sid"); active {
+		t.Fatal("expected session token to expire")
+	}
+}
+
+func TestTokenVerifierUsesConfigClock(t *testing.T) {
+	frozen := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v, err := NewTokenVerifier(newFrozenConfig(frozen))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := &identityClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(testEndpoint),
+			Subject:   "combo_123",
+			Audience:  jwt.ClaimStrings{string(testGameId)},
+			ExpiresAt: jwt.NewNumericDate(frozen.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(frozen),
+		},
+		Scope: "auth",
+	}
+	// The token expired long ago according to the wall clock, but not according to the frozen clock.
+	if _, err := v.VerifyIdentityToken(signToken(t, claims)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims.ExpiresAt = jwt.NewNumericDate(frozen.Add(-time.Second))
+	if _, err := v.VerifyIdentityToken(signToken(t, claims)); err == nil {
+		t.Fatal("expected error for token expired according to the frozen clock")
+	}
+}
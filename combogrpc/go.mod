@@ -0,0 +1,25 @@
+module github.com/seayoo-io/combo-sdk-go/combogrpc
+
+go 1.21.5
+
+require (
+	github.com/golang-jwt/jwt/v5 v5.2.2
+	github.com/seayoo-io/combo-sdk-go v0.0.0
+	google.golang.org/grpc v1.64.1
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/redis/go-redis/v9 v9.6.3 // indirect
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
+
+// 核心 module 发布包含 combogrpc 所需 API 的版本之前，使用同一仓库中的代码。
+// 发布之后，require 该版本并删除 replace。
+replace github.com/seayoo-io/combo-sdk-go => ../
@@ -0,0 +1,195 @@
+// Package combogrpc 提供了用于验证世游 Token 的 gRPC 服务端拦截器。
+//
+// 该 package 是一个独立的 Go module，只有需要 gRPC 的游戏服务才需要引入，Combo SDK 的核心 module 不依赖 gRPC。
+package combogrpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	combo "github.com/seayoo-io/combo-sdk-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultIdentityTokenKey 是默认携带 Identity Token 的 metadata key，值的格式为 "Bearer <token>"。
+	DefaultIdentityTokenKey = "authorization"
+
+	// DefaultAdTokenKey 是默认携带 AdToken 的 metadata key。
+	DefaultAdTokenKey = "x-combo-ad-token"
+)
+
+// Option 是函数式风格的的可选项，用于创建拦截器。
+type Option func(*interceptor)
+
+// WithMetadataKey 用于指定携带 Token 的 metadata key。
+//
+// 如果不指定，Identity Token 拦截器默认使用 DefaultIdentityTokenKey，AdToken 拦截器默认使用 DefaultAdTokenKey。
+func WithMetadataKey(key string) Option {
+	return func(i *interceptor) {
+		i.key = strings.ToLower(key)
+	}
+}
+
+// WithMethods 用于指定需要验证 Token 的 RPC 方法，格式为 "/package.Service/Method"。
+//
+// 如果不指定，则所有 RPC 方法都需要验证 Token。
+// 例如，只在发放广告激励的 RPC 上验证 AdToken：
+//
+//	combogrpc.AdTokenUnaryServerInterceptor(verifier, combogrpc.WithMethods("/game.Reward/Claim"))
+func WithMethods(fullMethods ...string) Option {
+	return func(i *interceptor) {
+		i.methods = make(map[string]bool, len(fullMethods))
+		for _, m := range fullMethods {
+			i.methods[m] = true
+		}
+	}
+}
+
+// WithSkipMethods 用于指定不需要验证 Token 的 RPC 方法，例如健康检查。
+func WithSkipMethods(fullMethods ...string) Option {
+	return func(i *interceptor) {
+		i.skipMethods = make(map[string]bool, len(fullMethods))
+		for _, m := range fullMethods {
+			i.skipMethods[m] = true
+		}
+	}
+}
+
+// UnaryServerInterceptor 创建一个验证 Identity Token 的 gRPC unary 拦截器。
+//
+// 验证通过后，IdentityPayload 会被写入 context 中，可通过 combo.IdentityFromContext 获取。
+// 验证不通过时，返回 codes.Unauthenticated。访问 Store 出错导致无法完成验证时（即 *combo.TokenStoreError），返回 codes.Unavailable。
+func UnaryServerInterceptor(verifier *combo.TokenVerifier, options ...Option) grpc.UnaryServerInterceptor {
+	i := newInterceptor(DefaultIdentityTokenKey, options)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !i.applies(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		ctx, err := i.verifyIdentity(ctx, verifier)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor 创建一个验证 Identity Token 的 gRPC stream 拦截器。
+//
+// 验证通过后，IdentityPayload 会被写入 stream 的 context 中，可通过 combo.IdentityFromContext 获取。
+// 验证不通过时，返回 codes.Unauthenticated。访问 Store 出错导致无法完成验证时（即 *combo.TokenStoreError），返回 codes.Unavailable。
+func StreamServerInterceptor(verifier *combo.TokenVerifier, options ...Option) grpc.StreamServerInterceptor {
+	i := newInterceptor(DefaultIdentityTokenKey, options)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !i.applies(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx, err := i.verifyIdentity(ss.Context(), verifier)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// AdTokenUnaryServerInterceptor 创建一个验证 AdToken 的 gRPC unary 拦截器，通常配合 WithMethods 用于发放广告激励的 RPC。
+//
+// 验证通过后，AdPayload 会被写入 context 中，可通过 AdFromContext 获取。
+// 验证不通过时，返回 codes.Unauthenticated。访问 Store 出错导致无法完成验证时（即 *combo.TokenStoreError），返回 codes.Unavailable。
+func AdTokenUnaryServerInterceptor(verifier *combo.TokenVerifier, options ...Option) grpc.UnaryServerInterceptor {
+	i := newInterceptor(DefaultAdTokenKey, options)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !i.applies(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		token := i.extractToken(ctx)
+		if token == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing ad token")
+		}
+		payload, err := verifier.VerifyAdToken(token)
+		if err != nil {
+			return nil, verifyError(err, "invalid ad token")
+		}
+		return handler(context.WithValue(ctx, adPayloadKey{}, payload), req)
+	}
+}
+
+type adPayloadKey struct{}
+
+// AdFromContext 从 context 中获取 AdTokenUnaryServerInterceptor 写入的 AdPayload。
+//
+// 如果 context 中没有 AdPayload，则返回 nil, false。
+func AdFromContext(ctx context.Context) (*combo.AdPayload, bool) {
+	payload, ok := ctx.Value(adPayloadKey{}).(*combo.AdPayload)
+	return payload, ok
+}
+
+type interceptor struct {
+	key         string
+	methods     map[string]bool
+	skipMethods map[string]bool
+}
+
+func newInterceptor(key string, options []Option) *interceptor {
+	i := &interceptor{key: key}
+	for _, option := range options {
+		option(i)
+	}
+	return i
+}
+
+func (i *interceptor) applies(fullMethod string) bool {
+	if i.skipMethods[fullMethod] {
+		return false
+	}
+	return i.methods == nil || i.methods[fullMethod]
+}
+
+// extractToken 从 incoming metadata 中提取 Token，并去掉可选的 "Bearer " 前缀。
+func (i *interceptor) extractToken(ctx context.Context) string {
+	values := metadata.ValueFromIncomingContext(ctx, i.key)
+	if len(values) == 0 {
+		return ""
+	}
+	token := strings.TrimSpace(values[0])
+	if scheme, rest, ok := strings.Cut(token, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		token = strings.TrimSpace(rest)
+	}
+	return token
+}
+
+func (i *interceptor) verifyIdentity(ctx context.Context, verifier *combo.TokenVerifier) (context.Context, error) {
+	token := i.extractToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing identity token")
+	}
+	payload, err := verifier.VerifyIdentityTokenContext(ctx, token)
+	if err != nil {
+		return nil, verifyError(err, "invalid identity token")
+	}
+	return combo.ContextWithIdentity(ctx, payload), nil
+}
+
+// verifyError 将 Token 验证失败的错误转换为 gRPC status。
+// 访问 Store 出错并不意味着 Token 无效，返回 codes.Unavailable 以便客户端重试，而不是要求用户重新登录。
+func verifyError(err error, message string) error {
+	var storeErr *combo.TokenStoreError
+	if errors.As(err, &storeErr) {
+		return status.Error(codes.Unavailable, "token store unavailable")
+	}
+	return status.Error(codes.Unauthenticated, message)
+}
+
+// serverStream 用于替换 grpc.ServerStream 的 context。
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,198 @@
+package combogrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	combo "github.com/seayoo-io/combo-sdk-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testEndpoint  = "https://api.test.com"
+	testGameId    = "test_game"
+	testSecretKey = "sk_test_secret_key_12345"
+)
+
+func newTestVerifier(t *testing.T) *combo.TokenVerifier {
+	t.Helper()
+	v, err := combo.NewTokenVerifier(combo.Config{
+		Endpoint:  testEndpoint,
+		GameId:    testGameId,
+		SecretKey: combo.SecretKey(testSecretKey),
+	})
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	return v
+}
+
+func signToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	claims["iss"] = testEndpoint
+	claims["aud"] = testGameId
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecretKey))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return tokenString
+}
+
+func identityToken(t *testing.T) string {
+	return signToken(t, jwt.MapClaims{"sub": "combo_123", "scope": "auth", "idp": "guest"})
+}
+
+func adToken(t *testing.T) string {
+	return signToken(t, jwt.MapClaims{"sub": "combo_123", "scope": "ads", "placement_id": "p1", "impression_id": "i1"})
+}
+
+func incomingContext(kv ...string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(kv...))
+}
+
+func assertUnauthenticated(t *testing.T, err error) {
+	t.Helper()
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected codes.Unauthenticated, got %v", err)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor(newTestVerifier(t))
+	info := &grpc.UnaryServerInfo{FullMethod: "/game.Player/GetProfile"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		payload, ok := combo.IdentityFromContext(ctx)
+		if !ok {
+			t.Fatal("expected identity payload in context")
+		}
+		return payload.ComboId, nil
+	}
+
+	resp, err := interceptor(incomingContext("authorization", "Bearer "+identityToken(t)), nil, info, handler)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp != "combo_123" {
+		t.Fatalf("expected combo_123, got %v", resp)
+	}
+
+	_, err = interceptor(context.Background(), nil, info, handler)
+	assertUnauthenticated(t, err)
+
+	_, err = interceptor(incomingContext("authorization", "Bearer invalid"), nil, info, handler)
+	assertUnauthenticated(t, err)
+
+	// Ad tokens must not be accepted as identity tokens.
+	_, err = interceptor(incomingContext("authorization", "Bearer "+adToken(t)), nil, info, handler)
+	assertUnauthenticated(t, err)
+}
+
+type failingRevocationStore struct {
+	combo.RevocationStore
+}
+
+func (failingRevocationStore) IsRevoked(ctx context.Context, check combo.RevocationCheck) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+func TestUnaryServerInterceptorStoreError(t *testing.T) {
+	v, err := combo.NewTokenVerifier(combo.Config{
+		Endpoint:  testEndpoint,
+		GameId:    testGameId,
+		SecretKey: combo.SecretKey(testSecretKey),
+	}, combo.WithRevocationStore(failingRevocationStore{}))
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	interceptor := UnaryServerInterceptor(v)
+	info := &grpc.UnaryServerInfo{FullMethod: "/game.Player/GetProfile"}
+	_, err = interceptor(incomingContext("authorization", "Bearer "+identityToken(t)), nil, info, func(ctx context.Context, req any) (any, error) {
+		t.Fatal("handler should not be called")
+		return nil, nil
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected codes.Unavailable, got %v", err)
+	}
+}
+
+func TestUnaryServerInterceptorSkipMethods(t *testing.T) {
+	interceptor := UnaryServerInterceptor(newTestVerifier(t), WithSkipMethods("/grpc.health.v1.Health/Check"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	called := false
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		called = true
+		return nil, nil
+	})
+	if err != nil || !called {
+		t.Fatalf("expected skipped method to be called without a token, err=%v", err)
+	}
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	interceptor := StreamServerInterceptor(newTestVerifier(t), WithMetadataKey("X-Identity-Token"))
+	info := &grpc.StreamServerInfo{FullMethod: "/game.Chat/Connect"}
+	var got *combo.IdentityPayload
+	handler := func(srv any, ss grpc.ServerStream) error {
+		got, _ = combo.IdentityFromContext(ss.Context())
+		return nil
+	}
+
+	ss := &testServerStream{ctx: incomingContext("x-identity-token", identityToken(t))}
+	if err := interceptor(nil, ss, info, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got == nil || got.ComboId != "combo_123" {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+
+	ss = &testServerStream{ctx: incomingContext("authorization", "Bearer "+identityToken(t))}
+	assertUnauthenticated(t, interceptor(nil, ss, info, handler))
+}
+
+func TestAdTokenUnaryServerInterceptor(t *testing.T) {
+	interceptor := AdTokenUnaryServerInterceptor(newTestVerifier(t), WithMethods("/game.Reward/Claim"))
+	handler := func(ctx context.Context, req any) (any, error) {
+		payload, ok := AdFromContext(ctx)
+		if !ok {
+			return nil, nil
+		}
+		return payload.PlacementId, nil
+	}
+
+	claim := &grpc.UnaryServerInfo{FullMethod: "/game.Reward/Claim"}
+	resp, err := interceptor(incomingContext(DefaultAdTokenKey, adToken(t)), nil, claim, handler)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp != "p1" {
+		t.Fatalf("expected placement p1, got %v", resp)
+	}
+
+	_, err = interceptor(incomingContext(DefaultAdTokenKey, identityToken(t)), nil, claim, handler)
+	assertUnauthenticated(t, err)
+
+	_, err = interceptor(context.Background(), nil, claim, handler)
+	assertUnauthenticated(t, err)
+
+	// Other methods do not require an ad token.
+	other := &grpc.UnaryServerInfo{FullMethod: "/game.Player/GetProfile"}
+	if _, err := interceptor(context.Background(), nil, other, handler); err != nil {
+		t.Fatalf("unexpected error for method without ad token: %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+// Package combotest 提供了在测试中颁发 Identity Token 和 AdToken 的辅助函数。
+//
+// 颁发的 Token 和世游服务端颁发的 Token 格式一致，可以被 combo.TokenVerifier 验证通过，
+// 游戏侧可以用它测试登录、广告激励等流程，而不必自行拼装 JWT。
+// 通过 Option 还可以颁发过期、scope 错误、audience 错误或被篡改的 Token，用于测试异常流程。
+//
+// 注意：combotest 仅用于测试，不应当在生产代码中使用。
+package combotest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	combo "github.com/seayoo-io/combo-sdk-go"
+	"github.com/seayoo-io/combo-sdk-go/internal/aesgcm"
+)
+
+const (
+	identityTokenScope = "auth"
+	adTokenScope       = "ads"
+
+	// DefaultTokenLifetime 是颁发的 Token 的默认有效期。
+	DefaultTokenLifetime = time.Hour
+)
+
+// Option 是函数式风格的的可选项，用于颁发 Token。
+type Option func(*options)
+
+type options struct {
+	issuedAt  time.Time
+	expiresAt time.Time
+	notBefore time.Time
+	lifetime  time.Duration
+	expired   bool
+	scope     *string
+	audience  *string
+	issuer    *string
+	tokenId   string
+	keyId     string
+	secretKey combo.SecretKey
+	claims    map[string]any
+	tampered  bool
+}
+
+// WithIssuedAt 用于指定 Token 的签发时间 iat。如果不指定，则默认为 Config.Clock 的当前时间。
+func WithIssuedAt(t time.Time) Option {
+	return func(o *options) {
+		o.issuedAt = t
+	}
+}
+
+// WithLifetime 用于指定 Token 的有效期，即 exp - iat。如果不指定，则默认为 DefaultTokenLifetime。
+func WithLifetime(d time.Duration) Option {
+	return func(o *options) {
+		o.lifetime = d
+	}
+}
+
+// WithExpiresAt 用于指定 Token 的过期时间 exp。指定后 WithLifetime 不再生效。
+func WithExpiresAt(t time.Time) Option {
+	return func(o *options) {
+		o.expiresAt = t
+	}
+}
+
+// WithNotBefore 用于指定 Token 的生效时间 nbf。如果不指定，则 Token 中不包含 nbf。
+func WithNotBefore(t time.Time) Option {
+	return func(o *options) {
+		o.notBefore = t
+	}
+}
+
+// Expired 用于颁发已经过期的 Token：签发时间为两个有效期之前，过期时间为一个有效期之前。
+// Expired 不能和 WithIssuedAt 或 WithExpiresAt 同时使用，否则颁发 Token 时返回错误。
+func Expired() Option {
+	return func(o *options) {
+		o.expired = true
+	}
+}
+
+// WithScope 用于指定 Token 的 scope。可用于颁发 scope 错误的 Token，例如用 AdToken 的 scope 颁发 Identity Token。
+func WithScope(scope string) Option {
+	return func(o *options) {
+		o.scope = &scope
+	}
+}
+
+// WithAudience 用于指定 Token 的 aud。如果不指定，则默认为 Config.GameId。
+func WithAudience(audience string) Option {
+	return func(o *options) {
+		o.audience = &audience
+	}
+}
+
+// WithIssuer 用于指定 Token 的 iss。如果不指定，则默认为 Config.Endpoint。
+func WithIssuer(issuer string) Option {
+	return func(o *options) {
+		o.issuer = &issuer
+	}
+}
+
+// WithTokenId 用于指定 Token 的 jti。如果不指定，则 Token 中不包含 jti。
+func WithTokenId(tokenId string) Option {
+	return func(o *options) {
+		o.tokenId = tokenId
+	}
+}
+
+// WithKeyId 用于指定 Token header 中的 kid。如果不指定，则 Token 中不包含 kid。
+func WithKeyId(keyId string) Option {
+	return func(o *options) {
+		o.keyId = keyId
+	}
+}
+
+// WithSecretKey 用于指定签名和加密使用的密钥。如果不指定，则默认使用 Config.SecretKey。
+// 可用于测试 Secret Key 轮换，或者颁发签名错误的 Token。
+func WithSecretKey(sk combo.SecretKey) Option {
+	return func(o *options) {
+		o.secretKey = sk
+	}
+}
+
+// WithClaim 用于在 Token 中添加额外的声明，也可以覆盖默认的声明。value 会被序列化为 JSON。
+func WithClaim(name string, value any) Option {
+	return func(o *options) {
+		if o.claims == nil {
+			o.claims = make(map[string]any)
+		}
+		o.claims[name] = value
+	}
+}
+
+// Tampered 用于颁发被篡改的 Token：签名之后修改了 Token 中的 sub，因此签名验证会失败。
+func Tampered() Option {
+	return func(o *options) {
+		o.tampered = true
+	}
+}
+
+// IssueIdentityToken 根据 payload 颁发一个 Identity Token。
+//
+// payload.WeixinSessionKey 会按照世游服务端的方式加密。
+// 如果 payload 来自 TokenVerifier 的验证结果，payload.Extra() 中的声明也会写入 Token。其他额外的声明可以通过 WithClaim 指定。
+func IssueIdentityToken(cfg combo.Config, payload combo.IdentityPayload, opts ...Option) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":           payload.ComboId,
+		"idp":           string(payload.IdP),
+		"external_id":   payload.ExternalId,
+		"external_name": payload.ExternalName,
+		"device_id":     payload.DeviceId,
+		"distro":        payload.Distro,
+		"variant":       payload.Variant,
+		"age":           payload.Age,
+		"reg_time":      payload.RegTime,
+	}
+	o := newOptions(cfg, opts)
+	if payload.WeixinSessionKey != "" {
+		nonce := make([]byte, aesgcm.NonceSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		encrypted, err := aesgcm.Encrypt(o.secretKey, payload.WeixinSessionKey, nonce)
+		if err != nil {
+			return "", fmt.Errorf("error encrypting weixin_session_key: %w", err)
+		}
+		claims["weixin_session_key"] = encrypted
+	}
+	return issue(cfg, o, identityTokenScope, claims, payload.Extra())
+}
+
+// IssueAdToken 根据 payload 颁发一个 AdToken。
+//
+// 如果 payload 来自 TokenVerifier 的验证结果，payload.Extra() 中的声明也会写入 Token。其他额外的声明可以通过 WithClaim 指定。
+func IssueAdToken(cfg combo.Config, payload combo.AdPayload, opts ...Option) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":           payload.ComboId,
+		"placement_id":  payload.PlacementId,
+		"impression_id": payload.ImpressionId,
+	}
+	return issue(cfg, newOptions(cfg, opts), adTokenScope, claims, payload.Extra())
+}
+
+func newOptions(cfg combo.Config, opts []Option) *options {
+	o := &options{secretKey: cfg.SecretKey, lifetime: DefaultTokenLifetime}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func issue(cfg combo.Config, o *options, scope string, claims jwt.MapClaims, extra map[string]json.RawMessage) (string, error) {
+	if len(o.secretKey) == 0 {
+		return "", errors.New("missing required SecretKey")
+	}
+	clock := cfg.Clock
+	if clock == nil {
+		clock = combo.SystemClock
+	}
+	if o.expired && (!o.issuedAt.IsZero() || !o.expiresAt.IsZero()) {
+		return "", errors.New("Expired cannot be combined with WithIssuedAt or WithExpiresAt")
+	}
+	issuedAt, expiresAt := o.issuedAt, o.expiresAt
+	if issuedAt.IsZero() {
+		issuedAt = clock.Now()
+		if o.expired {
+			issuedAt = issuedAt.Add(-2 * o.lifetime)
+		}
+	}
+	if expiresAt.IsZero() {
+		expiresAt = issuedAt.Add(o.lifetime)
+	}
+
+	claims["iss"] = strings.TrimSuffix(string(cfg.Endpoint), "/")
+	claims["aud"] = string(cfg.GameId)
+	claims["scope"] = scope
+	claims["iat"] = jwt.NewNumericDate(issuedAt)
+	claims["exp"] = jwt.NewNumericDate(expiresAt)
+	if !o.notBefore.IsZero() {
+		claims["nbf"] = jwt.NewNumericDate(o.notBefore)
+	}
+	if o.tokenId != "" {
+		claims["jti"] = o.tokenId
+	}
+	if o.issuer != nil {
+		claims["iss"] = *o.issuer
+	}
+	if o.audience != nil {
+		claims["aud"] = *o.audience
+	}
+	if o.scope != nil {
+		claims["scope"] = *o.scope
+	}
+	for name, value := range extra {
+		claims[name] = value
+	}
+	for name, value := range o.claims {
+		claims[name] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if o.keyId != "" {
+		token.Header["kid"] = o.keyId
+	}
+	signed, err := token.SignedString([]byte(o.secretKey))
+	if err != nil {
+		return "", fmt.Errorf("error signing token: %w", err)
+	}
+	if o.tampered {
+		return tamper(signed, claims)
+	}
+	return signed, nil
+}
+
+// tamper 修改 Token 中的 sub，但保留原来的签名。
+func tamper(signed string, claims jwt.MapClaims) (string, error) {
+	sub, _ := claims["sub"].(string)
+	claims["sub"] = sub + "_tampered"
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(signed, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString(data)
+	return strings.Join(parts, "."), nil
+}
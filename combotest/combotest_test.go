@@ -0,0 +1,167 @@
+package combotest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	combo "github.com/seayoo-io/combo-sdk-go"
+)
+
+func newTestConfig() combo.Config {
+	return combo.Config{
+		Endpoint:  "https://api.test.com/",
+		GameId:    "test_game",
+		SecretKey: combo.SecretKey("sk_test_secret_key_12345"),
+	}
+}
+
+func newTestVerifier(t *testing.T, cfg combo.Config) *combo.TokenVerifier {
+	t.Helper()
+	v, err := combo.NewTokenVerifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestIssueIdentityToken(t *testing.T) {
+	cfg := newTestConfig()
+	v := newTestVerifier(t, cfg)
+	want := combo.IdentityPayload{
+		ComboId:          "combo_123",
+		IdP:              combo.IdP_MinigameWeixin,
+		ExternalId:       "wx_openid_123",
+		WeixinSessionKey: "session_key_value",
+		DeviceId:         "device_1",
+		Distro:           "official",
+		Age:              16,
+		RegTime:          1700000000,
+	}
+	token, err := IssueIdentityToken(cfg, want, WithTokenId("jti_1"), WithClaim("vip_level", 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := v.VerifyIdentityToken(token)
+	if err != nil {
+		t.Fatalf("expected token to be accepted, got %v", err)
+	}
+	if got.ComboId != want.ComboId || got.IdP != want.IdP || got.ExternalId != want.ExternalId ||
+		got.WeixinSessionKey != want.WeixinSessionKey || got.DeviceId != want.DeviceId ||
+		got.Distro != want.Distro || got.Age != want.Age || got.RegTime != want.RegTime {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+	if string(got.Extra()["vip_level"]) != "3" {
+		t.Fatalf("expected extra claim to round trip, got %v", got.Extra())
+	}
+
+	// Extra claims of a verified payload are kept when it is issued again.
+	reissued, err := IssueIdentityToken(cfg, *got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := v.VerifyIdentityToken(reissued)
+	if err != nil {
+		t.Fatalf("expected token to be accepted, got %v", err)
+	}
+	if string(again.Extra()["vip_level"]) != "3" {
+		t.Fatalf("expected extra claim to be reissued, got %v", again.Extra())
+	}
+	if got.TokenId() != "jti_1" || got.ExpiresAt().Sub(got.IssuedAt()) != DefaultTokenLifetime {
+		t.Fatalf("unexpected token metadata: jti=%s iat=%v exp=%v", got.TokenId(), got.IssuedAt(), got.ExpiresAt())
+	}
+}
+
+func TestIssueAdToken(t *testing.T) {
+	cfg := newTestConfig()
+	v := newTestVerifier(t, cfg)
+	token, err := IssueAdToken(cfg, combo.AdPayload{ComboId: "combo_123", PlacementId: "placement_001", ImpressionId: "impression_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := v.VerifyAdToken(token)
+	if err != nil {
+		t.Fatalf("expected token to be accepted, got %v", err)
+	}
+	if got.ComboId != "combo_123" || got.PlacementId != "placement_001" || got.ImpressionId != "impression_001" {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+	if _, err := v.VerifyIdentityToken(token); err == nil {
+		t.Fatal("expected ad token to be rejected as identity token")
+	}
+}
+
+func TestIssueInvalidTokens(t *testing.T) {
+	cfg := newTestConfig()
+	v := newTestVerifier(t, cfg)
+	payload := combo.IdentityPayload{ComboId: "combo_123"}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr error
+	}{
+		{"expired", []Option{Expired()}, jwt.ErrTokenExpired},
+		{"not yet valid", []Option{WithNotBefore(time.Now().Add(time.Hour))}, jwt.ErrTokenNotValidYet},
+		{"wrong audience", []Option{WithAudience("other_game")}, jwt.ErrTokenInvalidAudience},
+		{"wrong issuer", []Option{WithIssuer("https://evil.example.com")}, jwt.ErrTokenInvalidIssuer},
+		{"wrong secret key", []Option{WithSecretKey(combo.SecretKey("sk_other"))}, jwt.ErrTokenSignatureInvalid},
+		{"tampered", []Option{Tampered()}, jwt.ErrTokenSignatureInvalid},
+		{"wrong scope", []Option{WithScope("ads")}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := IssueIdentityToken(cfg, payload, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = v.VerifyIdentityToken(token)
+			if err == nil {
+				t.Fatal("expected token to be rejected")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestIssueExpiredWithExplicitTimes(t *testing.T) {
+	cfg := newTestConfig()
+	payload := combo.IdentityPayload{ComboId: "combo_123"}
+	for _, opt := range []Option{WithIssuedAt(time.Now()), WithExpiresAt(time.Now().Add(time.Hour))} {
+		if _, err := IssueIdentityToken(cfg, payload, opt, Expired()); err == nil {
+			t.Fatal("expected error for Expired combined with explicit times")
+		}
+	}
+}
+
+func TestIssueWithConfigClock(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	cfg := newTestConfig()
+	cfg.Clock = combo.ClockFunc(func() time.Time { return now })
+	v := newTestVerifier(t, cfg)
+
+	token, err := IssueIdentityToken(cfg, combo.IdentityPayload{ComboId: "combo_123"}, WithLifetime(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := v.VerifyIdentityToken(token)
+	if err != nil {
+		t.Fatalf("expected token to be accepted, got %v", err)
+	}
+	if !got.IssuedAt().Equal(now) || !got.ExpiresAt().Equal(now.Add(time.Minute)) {
+		t.Fatalf("unexpected token times: iat=%v exp=%v", got.IssuedAt(), got.ExpiresAt())
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := v.VerifyIdentityToken(token); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Fatalf("expected expired token, got %v", err)
+	}
+}
+
+func TestIssueMissingSecretKey(t *testing.T) {
+	if _, err := IssueAdToken(combo.Config{}, combo.AdPayload{}); err == nil {
+		t.Fatal("expected error for missing SecretKey")
+	}
+}
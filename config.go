@@ -2,6 +2,7 @@ package combo
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 )
 
@@ -15,6 +16,23 @@ type Config struct {
 
 	// 游戏的 Secret Key
 	SecretKey SecretKey
+
+	// 游戏的 Secret Key 的标识，可选。
+	// 如果指定，则对外发送的 HTTP 请求的签名中会携带此标识。
+	SecretKeyId string
+
+	// 在 Secret Key 轮换期间仍然被接受的旧密钥，可选。
+	//
+	// 对外发送的 HTTP 请求总是使用 SecretKey 签名。
+	// 入站 HTTP 请求的签名、Identity Token 以及 Token 中的加密字段，只要能被 SecretKey 或任意一个 AcceptedSecretKeys 验证通过即可。
+	AcceptedSecretKeys []AcceptedSecretKey
+
+	// 在入站数据被某个密钥验证通过时调用，可选。可用于上报密钥的使用情况。
+	OnKeyMatch KeyMatchFunc
+
+	// 用于获取当前时间，可选。如果不指定，则默认使用 SystemClock。
+	// 游戏侧可以在测试中指定固定的时间，从而让签名、Token 验证等与时间相关的逻辑使用同一个时间。
+	Clock Clock
 }
 
 func (cfg *Config) validate() error {
@@ -22,6 +40,9 @@ func (cfg *Config) validate() error {
 		return errors.New("missing required Endpoint")
 	}
 	cfg.Endpoint = Endpoint(strings.TrimSuffix(string(cfg.Endpoint), "/"))
+	if cfg.Clock == nil {
+		cfg.Clock = SystemClock
+	}
 	if cfg.GameId == "" {
 		return errors.New("missing required GameId")
 	}
@@ -31,5 +52,15 @@ func (cfg *Config) validate() error {
 	if !strings.HasPrefix(string(cfg.SecretKey), "sk_") {
 		return errors.New("invalid SecretKey: must start with sk_")
 	}
+	keyIds := map[string]bool{cfg.SecretKeyId: true}
+	for i, k := range cfg.AcceptedSecretKeys {
+		if !strings.HasPrefix(string(k.SecretKey), "sk_") {
+			return fmt.Errorf("invalid AcceptedSecretKeys[%d]: must start with sk_", i)
+		}
+		if k.KeyId != "" && keyIds[k.KeyId] {
+			return fmt.Errorf("invalid AcceptedSecretKeys[%d]: duplicate KeyId %s", i, k.KeyId)
+		}
+		keyIds[k.KeyId] = true
+	}
 	return nil
 }
@@ -5,8 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	"strings"
-	"time"
 )
 
 // NewGmHandler 创建一个用于处理世游服务端发送的 GM 命令的 http.Handler。
@@ -14,20 +12,17 @@ import (
 // 游戏侧需要将此 Handler 注册到游戏的 HTTP 服务中。
 //
 // 注意：注册 Handler 时，应当使用 HTTP POST。
-func NewGmHandler(cfg Config, listener GmListener) (http.Handler, error) {
+func NewGmHandler(cfg Config, listener GmListener, options ...HandlerOption) (http.Handler, error) {
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
 	if listener == nil {
 		return nil, errors.New("missing required listener")
 	}
-	return &gmHandler{
-		signer: httpSigner{
-			game:       cfg.GameId,
-			signingKey: cfg.SecretKey,
-		},
+	handler := &gmHandler{
 		listener: listener,
-	}, nil
+	}
+	return newSignatureHandler(cfg, handler, GmErrorWriter, options), nil
 }
 
 // GmListener 是一个用于接收并处理世游服务端发送的 GM 命令的接口。
@@ -102,6 +97,9 @@ const (
 
 	// 幂等处理重试请求时，请求内容和 idempotency_key 所对应的原始请求内容不一致。
 	GmError_IdempotencyMismatch GmError = "idempotency_mismatch"
+
+	// 请求的 body 超出了游戏侧允许的最大长度。
+	GmError_RequestTooLarge GmError = "request_too_large"
 )
 
 // 服务端错误。
@@ -135,6 +133,7 @@ var gmError2HttpStatus = map[GmError]int{
 	GmError_ThrottlingError:     http.StatusTooManyRequests,
 	GmError_IdempotencyConflict: http.StatusConflict,
 	GmError_IdempotencyMismatch: http.StatusUnprocessableEntity,
+	GmError_RequestTooLarge:     http.StatusRequestEntityTooLarge,
 	GmError_MaintenanceError:    http.StatusServiceUnavailable,
 	GmError_NetworkError:        http.StatusInternalServerError,
 	GmError_DatabaseError:       http.StatusInternalServerError,
@@ -152,43 +151,20 @@ type gmRequestBody struct {
 }
 
 type gmHandler struct {
-	signer   httpSigner
 	listener GmListener
 }
 
 func (h *gmHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.json(w, http.StatusMethodNotAllowed, GmErrorResponse{
-			Error:   GmError_InvalidHttpMethod,
-			Message: "Expecting POST, got " + r.Method,
-		})
-		return
-	}
-	contentType := r.Header.Get("Content-Type")
-	if !strings.HasPrefix(contentType, "application/json") {
-		h.json(w, http.StatusUnsupportedMediaType, GmErrorResponse{
-			Error:   GmError_InvalidContentType,
-			Message: "Expecting application/json, got " + contentType,
-		})
-		return
-	}
-	if err := h.signer.AuthHttp(r, time.Now()); err != nil {
-		h.json(w, http.StatusUnauthorized, GmErrorResponse{
-			Error:   GmError_InvalidSignature,
-			Message: err.Error(),
-		})
-		return
-	}
 	var body gmRequestBody
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		h.json(w, http.StatusBadRequest, GmErrorResponse{
+		writeJson(w, http.StatusBadRequest, GmErrorResponse{
 			Error:   GmError_InvalidRequest,
 			Message: err.Error(),
 		})
 		return
 	}
 	if err := h.validateRequestBody(&body); err != nil {
-		h.json(w, http.StatusBadRequest, err)
+		writeJson(w, http.StatusBadRequest, err)
 		return
 	}
 	resp, err := h.listener.HandleGmRequest(r.Context(), &GmRequest{
@@ -204,10 +180,10 @@ func (h *gmHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if !ok {
 			status = http.StatusInternalServerError
 		}
-		h.json(w, status, err)
+		writeJson(w, status, err)
 		return
 	}
-	h.json(w, http.StatusOK, resp)
+	writeJson(w, http.StatusOK, resp)
 }
 
 func (h *gmHandler) validateRequestBody(body *gmRequestBody) *GmErrorResponse {
@@ -237,13 +213,3 @@ func (h *gmHandler) validateRequestBody(body *gmRequestBody) *GmErrorResponse {
 	}
 	return nil
 }
-
-func (h *gmHandler) json(w http.ResponseWriter, code int, obj any) {
-	header := w.Header()
-	header["Content-Type"] = jsonContentType
-	w.WriteHeader(code)
-	jsonBytes, _ := json.Marshal(obj)
-	if jsonBytes != nil {
-		_, _ = w.Write(jsonBytes)
-	}
-}
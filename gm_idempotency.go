@@ -91,6 +91,19 @@ type IdempotencyStore interface {
 	SetXX(ctx context.Context, key, value string) error
 }
 
+// AdRedemptionStore 是一个用于存储广告激励兑换记录的接口，在 IdempotencyStore 的基础上，支持指定记录的最短保留时间以及删除记录。
+//
+// NewMemoryIdempotencyStore() 和 NewRedisIdempotencyStore() 创建的 IdempotencyStore 均实现了该接口，可直接用于 AdRewardRedeemer。
+type AdRedemptionStore interface {
+	IdempotencyStore
+
+	// SetNXWithTTL 和 SetNX 相同，但是记录至少会保留 ttl。实现可以保留更长的时间，例如 IdempotencyStore 默认的过期时间。
+	SetNXWithTTL(ctx context.Context, key, value string, ttl time.Duration) (string, error)
+
+	// Delete 删除记录。如果 key 不存在，则什么也不做。
+	Delete(ctx context.Context, key string) error
+}
+
 type idempotentGmListener struct {
 	store  IdempotencyStore
 	real   GmListener
@@ -255,6 +268,19 @@ func (s *memoryIdempotencyStore) SetXX(ctx context.Context, key string, value st
 	return nil
 }
 
+// SetNXWithTTL implements AdRedemptionStore. 数据不会过期，所以忽略 ttl。
+func (s *memoryIdempotencyStore) SetNXWithTTL(ctx context.Context, key string, value string, ttl time.Duration) (string, error) {
+	return s.SetNX(ctx, key, value)
+}
+
+// Delete implements AdRedemptionStore.
+func (s *memoryIdempotencyStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.kv, key)
+	return nil
+}
+
 type redisIdempotencyStore struct {
 	client redis.Cmdable
 	ttl    time.Duration
@@ -263,11 +289,17 @@ type redisIdempotencyStore struct {
 
 // SetNX implements IdempotencyStore.
 func (s *redisIdempotencyStore) SetNX(ctx context.Context, key string, value string) (string, error) {
+	return s.SetNXWithTTL(ctx, key, value, 0)
+}
+
+// SetNXWithTTL implements AdRedemptionStore. 实际的过期时间是 ttl 和 RedisIdempotencyStoreConfig.TTL 中较长的一个。
+func (s *redisIdempotencyStore) SetNXWithTTL(ctx context.Context, key string, value string, ttl time.Duration) (string, error) {
+	ttl = max(ttl, s.ttl)
 	// Note: Using NX and GET options together requires Redis >= 7.0
 	// See: https://redis.io/docs/latest/commands/set/
 	oldValue, err := s.client.SetArgs(ctx, s.prefix+key, value, redis.SetArgs{
 		Mode: "NX",
-		TTL:  s.ttl,
+		TTL:  ttl,
 		Get:  true,
 	}).Result()
 	if err == redis.Nil {
@@ -286,3 +318,8 @@ func (s *redisIdempotencyStore) SetXX(ctx context.Context, key string, value str
 		KeepTTL: true,
 	}).Err()
 }
+
+// Delete implements AdRedemptionStore.
+func (s *redisIdempotencyStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
@@ -481,6 +481,31 @@ func TestRedisIdempotencyStoreSetXXKeepsTTL(t *testing.T) {
 	}
 }
 
+func TestRedisIdempotencyStoreSetNXWithTTLAndDelete(t *testing.T) {
+	store, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	redemptions := store.(AdRedemptionStore)
+
+	if _, err := redemptions.SetNXWithTTL(ctx, "long", "value", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := mr.TTL("long"); ttl != time.Hour {
+		t.Fatalf("expected ttl to be 1h, got %v", ttl)
+	}
+	if _, err := redemptions.SetNXWithTTL(ctx, "short", "value", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := mr.TTL("short"); ttl != 10*time.Minute {
+		t.Fatalf("expected ttl to fall back to the store ttl, got %v", ttl)
+	}
+	if err := redemptions.Delete(ctx, "long"); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists("long") {
+		t.Fatal("expected key to be deleted")
+	}
+}
+
 func TestRedisIdempotencyStoreConnectionError(t *testing.T) {
 	mr := miniredis.RunT(t)
 	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
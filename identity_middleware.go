@@ -0,0 +1,173 @@
+package combo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenSource 用于从 HTTP 请求中提取 Identity Token。如果请求中没有 Token，则返回空字符串。
+type TokenSource func(r *http.Request) string
+
+// BearerTokenSource 从 Authorization: Bearer <token> header 中提取 Token。
+func BearerTokenSource() TokenSource {
+	return func(r *http.Request) string {
+		scheme, token, ok := strings.Cut(r.Header.Get(authorizationHeader), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return ""
+		}
+		return strings.TrimSpace(token)
+	}
+}
+
+// CookieTokenSource 从名为 name 的 cookie 中提取 Token。
+func CookieTokenSource(name string) TokenSource {
+	return func(r *http.Request) string {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+// QueryTokenSource 从名为 name 的 query 参数中提取 Token。
+//
+// 注意：URL 通常会被记录在访问日志中，Token 可能因此泄露。仅在无法使用 header 或 cookie 的场景下使用，例如 WebSocket。
+func QueryTokenSource(name string) TokenSource {
+	return func(r *http.Request) string {
+		return r.URL.Query().Get(name)
+	}
+}
+
+// IdentityOption 是函数式风格的的可选项，用于创建 IdentityMiddleware。
+type IdentityOption func(*identityMiddleware)
+
+// WithTokenSources 用于指定 Identity Token 的来源。多个来源按顺序尝试，使用第一个非空的 Token。
+//
+// 如果不指定，则默认只使用 BearerTokenSource()。
+func WithTokenSources(sources ...TokenSource) IdentityOption {
+	return func(m *identityMiddleware) {
+		m.sources = sources
+	}
+}
+
+// WithRealm 用于指定 401 响应的 WWW-Authenticate header 中的 realm。
+//
+// 如果不指定，则默认为 Config.GameId。
+func WithRealm(realm string) IdentityOption {
+	return func(m *identityMiddleware) {
+		m.realm = realm
+	}
+}
+
+// IdentityMiddleware 创建一个 HTTP 中间件，用于验证游戏客户端请求中携带的 Identity Token。
+//
+// 验证通过后，IdentityPayload 会被写入请求的 context 中，可通过 IdentityFromContext 获取。
+// 验证不通过时，返回 401 Unauthorized，并在 WWW-Authenticate header 中说明原因，不会调用 next handler。
+// 如果是访问 Store 出错导致无法完成验证（即 *TokenStoreError），则返回 503 Service Unavailable，以免 Store 故障时所有用户都被登出。
+//
+//	mux.Handle("/api/", combo.IdentityMiddleware(verifier)(apiHandler))
+func IdentityMiddleware(verifier *TokenVerifier, options ...IdentityOption) func(http.Handler) http.Handler {
+	m := &identityMiddleware{
+		verifier: verifier,
+		sources:  []TokenSource{BearerTokenSource()},
+		realm:    string(verifier.game),
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.serveHTTP(w, r, next)
+		})
+	}
+}
+
+type identityPayloadKey struct{}
+
+// ContextWithIdentity 返回一个携带了 IdentityPayload 的新 context。
+//
+// IdentityMiddleware 使用它写入 IdentityPayload。游戏侧在自行验证 Identity Token 的场景下（例如 gRPC 拦截器），
+// 也可以使用它写入 IdentityPayload，以便通过 IdentityFromContext 统一获取。
+func ContextWithIdentity(ctx context.Context, payload *IdentityPayload) context.Context {
+	return context.WithValue(ctx, identityPayloadKey{}, payload)
+}
+
+// IdentityFromContext 从 context 中获取 IdentityMiddleware 或 ContextWithIdentity 写入的 IdentityPayload。
+//
+// 如果 context 中没有 IdentityPayload，则返回 nil, false。
+func IdentityFromContext(ctx context.Context) (*IdentityPayload, bool) {
+	payload, ok := ctx.Value(identityPayloadKey{}).(*IdentityPayload)
+	return payload, ok
+}
+
+type identityMiddleware struct {
+	verifier *TokenVerifier
+	sources  []TokenSource
+	realm    string
+}
+
+func (m *identityMiddleware) serveHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	token := m.extractToken(r)
+	if token == "" {
+		// 请求中没有携带任何凭据时，按照 RFC 6750 的要求，不返回 error 参数。
+		m.writeUnauthorized(w, "")
+		return
+	}
+	payload, err := m.verifier.VerifyIdentityTokenContext(r.Context(), token)
+	if err != nil {
+		var storeErr *TokenStoreError
+		if errors.As(err, &storeErr) {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		m.writeUnauthorized(w, describeTokenError(err))
+		return
+	}
+	next.ServeHTTP(w, r.WithContext(ContextWithIdentity(r.Context(), payload)))
+}
+
+func (m *identityMiddleware) extractToken(r *http.Request) string {
+	for _, source := range m.sources {
+		if token := source(r); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+func (m *identityMiddleware) writeUnauthorized(w http.ResponseWriter, description string) {
+	challenge := "Bearer realm=" + strconv.Quote(m.realm)
+	if description != "" {
+		challenge += `, error="invalid_token", error_description=` + strconv.Quote(description)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// describeTokenError 返回 Token 验证失败的简要描述，用于 WWW-Authenticate header。
+// 这里不直接使用 err.Error()，以免向客户端暴露过多的内部细节。
+func describeTokenError(err error) string {
+	var policyErr *TokenPolicyError
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "the token has expired"
+	case errors.Is(err, ErrTokenRevoked):
+		return "the token has been revoked"
+	case errors.Is(err, ErrTokenAlreadyUsed):
+		return "the token has already been used"
+	case errors.Is(err, ErrSessionDisplaced):
+		return "the session has been displaced by another device"
+	case errors.Is(err, ErrMissingLoginTime):
+		return "the token has no issued at time"
+	case errors.As(err, &policyErr):
+		return "the token is not allowed"
+	default:
+		return "the token is invalid"
+	}
+}
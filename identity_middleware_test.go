@@ -0,0 +1,161 @@
+package combo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func serveIdentityMiddleware(t *testing.T, v *TokenVerifier, req *http.Request, options ...IdentityOption) (*httptest.ResponseRecorder, *IdentityPayload) {
+	t.Helper()
+	var got *IdentityPayload
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, ok := IdentityFromContext(r.Context())
+		if !ok {
+			t.Error("expected identity payload in context")
+		}
+		got = payload
+	})
+	rec := httptest.NewRecorder()
+	IdentityMiddleware(v, options...)(next).ServeHTTP(rec, req)
+	return rec, got
+}
+
+func TestIdentityMiddlewareBearerToken(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, newTestIdentityClaims(now)))
+	rec, payload := serveIdentityMiddleware(t, v, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	if payload == nil || payload.ComboId != "combo_123" {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestIdentityMiddlewareTokenSources(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now)
+	tokenString := signToken(t, newTestIdentityClaims(now))
+	sources := WithTokenSources(CookieTokenSource("combo_token"), QueryTokenSource("token"))
+
+	cookieReq := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	cookieReq.AddCookie(&http.Cookie{Name: "combo_token", Value: tokenString})
+	if rec, _ := serveIdentityMiddleware(t, v, cookieReq, sources); rec.Code != http.StatusOK {
+		t.Fatalf("expected cookie token to be accepted, got %d", rec.Code)
+	}
+
+	queryReq := httptest.NewRequest(http.MethodGet, "/ws?token="+tokenString, nil)
+	if rec, _ := serveIdentityMiddleware(t, v, queryReq, sources); rec.Code != http.StatusOK {
+		t.Fatalf("expected query token to be accepted, got %d", rec.Code)
+	}
+
+	bearerReq := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	bearerReq.Header.Set("Authorization", "Bearer "+tokenString)
+	if rec, _ := serveIdentityMiddleware(t, v, bearerReq, sources); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected bearer token to be ignored, got %d", rec.Code)
+	}
+}
+
+func TestIdentityMiddlewareUnauthorized(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now)
+	expired := newTestIdentityClaims(now.Add(-2 * time.Hour))
+	expired.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Hour))
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantChallenge string
+	}{
+		{
+			name:          "missing token",
+			wantChallenge: `Bearer realm="test_game"`,
+		},
+		{
+			name:          "wrong scheme",
+			authorization: "Basic dXNlcjpwYXNz",
+			wantChallenge: `Bearer realm="test_game"`,
+		},
+		{
+			name:          "malformed token",
+			authorization: "Bearer not-a-jwt",
+			wantChallenge: `Bearer realm="test_game", error="invalid_token", error_description="the token is invalid"`,
+		},
+		{
+			name:          "expired token",
+			authorization: "Bearer " + signToken(t, expired),
+			wantChallenge: `Bearer realm="test_game", error="invalid_token", error_description="the token has expired"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/profile", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			called := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+			rec := httptest.NewRecorder()
+			IdentityMiddleware(v)(next).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("expected %d, got %d", http.StatusUnauthorized, rec.Code)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Fatalf("expected challenge %s, got %s", tt.wantChallenge, got)
+			}
+			if called {
+				t.Fatal("next handler should not be called")
+			}
+		})
+	}
+}
+
+func TestIdentityMiddlewareStoreError(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now, WithRevocationStore(failingRevocationStore{}))
+	req := httptest.NewRequest(http.MethodGet, "/api/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, newTestIdentityClaims(now)))
+
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+	rec := httptest.NewRecorder()
+	IdentityMiddleware(v)(next).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if got := rec.Header().Get("WWW-Authenticate"); got != "" {
+		t.Fatalf("expected no challenge for store error, got %s", got)
+	}
+	if called {
+		t.Fatal("next handler should not be called")
+	}
+}
+
+func TestIdentityMiddlewareWithRealm(t *testing.T) {
+	v := newTestVerifier(t)
+	req := httptest.NewRequest(http.MethodGet, "/api/profile", nil)
+	rec := httptest.NewRecorder()
+	IdentityMiddleware(v, WithRealm("my-game"))(http.NotFoundHandler()).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("WWW-Authenticate"); !strings.Contains(got, `realm="my-game"`) {
+		t.Fatalf("expected custom realm, got %s", got)
+	}
+}
+
+func TestIdentityFromContextEmpty(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if payload, ok := IdentityFromContext(req.Context()); ok || payload != nil {
+		t.Fatal("expected no identity payload")
+	}
+}
@@ -0,0 +1,123 @@
+package combo
+
+// IdPRegion 是 IdP 可用的发行区域。
+type IdPRegion string
+
+const (
+	// 仅用于国内发行。
+	IdPRegion_China IdPRegion = "china"
+
+	// 仅用于海外发行。
+	IdPRegion_Global IdPRegion = "global"
+
+	// 国内发行和海外发行均可使用。
+	IdPRegion_Both IdPRegion = "both"
+)
+
+// IdPCategory 是 IdP 的分类。
+type IdPCategory string
+
+const (
+	// 世游自有的账号体系，例如游客登录、世游通行证。
+	IdPCategory_Seayoo IdPCategory = "seayoo"
+
+	// 应用商店和游戏渠道，例如手机厂商的应用商店、TapTap、B站。
+	IdPCategory_Store IdPCategory = "store"
+
+	// 社交平台和第三方账号，例如微信、Google Account。
+	IdPCategory_Social IdPCategory = "social"
+
+	// 小游戏平台，例如微信小游戏、抖音小游戏。
+	IdPCategory_Minigame IdPCategory = "minigame"
+
+	// 安卓模拟器，例如雷电模拟器、MuMu 模拟器。
+	IdPCategory_Emulator IdPCategory = "emulator"
+)
+
+// IdPInfo 包含了 IdP 的元数据。
+type IdPInfo struct {
+	IdP IdP
+
+	// 中文显示名称。
+	Name string
+
+	// 英文显示名称。
+	EnglishName string
+
+	// 可用的发行区域。SDK 未知的 IdP 为空字符串。
+	Region IdPRegion
+
+	// IdP 的分类。SDK 未知的 IdP 为空字符串。
+	Category IdPCategory
+}
+
+// idpCatalog 是 SDK 已知的所有 IdP，顺序和 model.go 中的常量定义一致。
+var idpCatalog = []IdPInfo{
+	{IdP_Guest, "游客登录", "Guest", IdPRegion_Both, IdPCategory_Seayoo},
+	{IdP_Seayoo, "世游通行证", "Seayoo Account", IdPRegion_Both, IdPCategory_Seayoo},
+	{IdP_Apple, "Apple 登录", "Sign in with Apple", IdPRegion_Both, IdPCategory_Social},
+	{IdP_Google, "Google 账号", "Google Account", IdPRegion_Global, IdPCategory_Social},
+	{IdP_Facebook, "Facebook 登录", "Facebook Login", IdPRegion_Global, IdPCategory_Social},
+	{IdP_Xiaomi, "小米账号", "Xiaomi Account", IdPRegion_China, IdPCategory_Store},
+	{IdP_Weixin, "微信登录", "WeChat", IdPRegion_China, IdPCategory_Social},
+	{IdP_Oppo, "OPPO 账号", "OPPO Account", IdPRegion_China, IdPCategory_Store},
+	{IdP_Vivo, "vivo 账号", "vivo Account", IdPRegion_China, IdPCategory_Store},
+	{IdP_Huawei, "华为账号", "HUAWEI ID", IdPRegion_China, IdPCategory_Store},
+	{IdP_Honor, "荣耀账号", "HONOR ID", IdPRegion_China, IdPCategory_Store},
+	{IdP_UC, "九游", "UC (9Game)", IdPRegion_China, IdPCategory_Store},
+	{IdP_TapTap, "TapTap", "TapTap", IdPRegion_Both, IdPCategory_Store},
+	{IdP_Bilibili, "哔哩哔哩", "bilibili", IdPRegion_China, IdPCategory_Store},
+	{IdP_Yingyongbao, "应用宝", "Tencent MyApp", IdPRegion_China, IdPCategory_Store},
+	{IdP_4399, "4399", "4399", IdPRegion_China, IdPCategory_Store},
+	{IdP_Douyin, "抖音", "Douyin", IdPRegion_China, IdPCategory_Social},
+	{IdP_Leidian, "雷电模拟器", "LDPlayer", IdPRegion_China, IdPCategory_Emulator},
+	{IdP_Maowo, "猫窝游戏", "Maowo Games", IdPRegion_China, IdPCategory_Store},
+	{IdP_Lenovo, "联想", "Lenovo", IdPRegion_China, IdPCategory_Store},
+	{IdP_Meizu, "魅族", "Meizu", IdPRegion_China, IdPCategory_Store},
+	{IdP_Coolpad, "酷派", "Coolpad", IdPRegion_China, IdPCategory_Store},
+	{IdP_Nubia, "努比亚", "nubia", IdPRegion_China, IdPCategory_Store},
+	{IdP_Juefeng, "绝峰游戏", "Juefeng Games", IdPRegion_China, IdPCategory_Store},
+	{IdP_Meituo, "魅拓游戏", "Meituo Games", IdPRegion_China, IdPCategory_Store},
+	{IdP_MinigameWeixin, "微信小游戏", "WeChat Mini Games", IdPRegion_China, IdPCategory_Minigame},
+	{IdP_MinigameDouyin, "抖音小游戏", "Douyin Mini Games", IdPRegion_China, IdPCategory_Minigame},
+	{IdP_Mumu, "MuMu 模拟器", "MuMu Player", IdPRegion_China, IdPCategory_Emulator},
+	{IdP_Huya, "虎牙游戏", "Huya Games", IdPRegion_China, IdPCategory_Store},
+	{IdP_Hykb, "好游快爆", "Haoyou Kuaibao", IdPRegion_China, IdPCategory_Store},
+	{IdP_233, "233 乐园", "233 Leyuan", IdPRegion_China, IdPCategory_Store},
+}
+
+var idpIndex = func() map[IdP]int {
+	index := make(map[IdP]int, len(idpCatalog))
+	for i, info := range idpCatalog {
+		index[info.IdP] = i
+	}
+	return index
+}()
+
+// AllIdPs 返回 SDK 已知的所有 IdP。
+func AllIdPs() []IdP {
+	idps := make([]IdP, len(idpCatalog))
+	for i, info := range idpCatalog {
+		idps[i] = info.IdP
+	}
+	return idps
+}
+
+// Valid 判断 idp 是否是 SDK 已知的 IdP。
+//
+// 世游服务端新增 IdP 之后，旧版本的 SDK 仍然可以正常验证 Token，只是 Valid 会返回 false。
+// 因此游戏侧不应当仅凭 Valid 为 false 就拒绝用户登录。
+func (idp IdP) Valid() bool {
+	_, ok := idpIndex[idp]
+	return ok
+}
+
+// Info 返回 idp 的元数据，第二个返回值表示 idp 是否是 SDK 已知的 IdP。
+//
+// 对于 SDK 未知的 IdP，返回的 IdPInfo 中 Name 和 EnglishName 均为 idp 本身，以便直接用于显示，Region 和 Category 为空字符串。
+func (idp IdP) Info() (IdPInfo, bool) {
+	if i, ok := idpIndex[idp]; ok {
+		return idpCatalog[i], true
+	}
+	return IdPInfo{IdP: idp, Name: string(idp), EnglishName: string(idp)}, false
+}
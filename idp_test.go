@@ -0,0 +1,69 @@
+package combo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllIdPs(t *testing.T) {
+	idps := AllIdPs()
+	if len(idps) != len(idpCatalog) {
+		t.Fatalf("expected %d IdPs, got %d", len(idpCatalog), len(idps))
+	}
+	seen := make(map[IdP]bool)
+	for _, idp := range idps {
+		if seen[idp] {
+			t.Fatalf("duplicate IdP: %s", idp)
+		}
+		seen[idp] = true
+		info, ok := idp.Info()
+		if !ok || !idp.Valid() {
+			t.Fatalf("expected %s to be known", idp)
+		}
+		if info.Name == "" || info.EnglishName == "" || info.Region == "" || info.Category == "" {
+			t.Fatalf("incomplete metadata for %s: %+v", idp, info)
+		}
+	}
+
+	// The returned slice is a copy.
+	idps[0] = "modified"
+	if AllIdPs()[0] != IdP_Guest {
+		t.Fatal("expected AllIdPs to return a copy")
+	}
+}
+
+func TestIdPInfo(t *testing.T) {
+	info, ok := IdP_MinigameWeixin.Info()
+	if !ok || info.Name != "微信小游戏" || info.Region != IdPRegion_China || info.Category != IdPCategory_Minigame {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	if Idp_MinigameWeixin != IdP_MinigameWeixin {
+		t.Fatal("expected deprecated alias to equal the new constant")
+	}
+}
+
+func TestUnknownIdP(t *testing.T) {
+	idp := IdP("new_store")
+	if idp.Valid() {
+		t.Fatal("expected unknown IdP to be invalid")
+	}
+	info, ok := idp.Info()
+	if ok || info.IdP != idp || info.Name != "new_store" || info.EnglishName != "new_store" || info.Region != "" || info.Category != "" {
+		t.Fatalf("unexpected info for unknown IdP: %+v, ok=%v", info, ok)
+	}
+
+	// Tokens with an unknown IdP are still accepted.
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	claims := newTestIdentityClaims(now)
+	claims.IdP = "new_store"
+	payload, err := newTestVerifierWithOptions(t, now).VerifyIdentityToken(signToken(t, claims))
+	if err != nil {
+		t.Fatalf("expected token with unknown IdP to be accepted, got %v", err)
+	}
+	if payload.IdP != idp || payload.IdP.Valid() {
+		t.Fatalf("unexpected IdP: %s", payload.IdP)
+	}
+}
+
+// 这些常量在旧版本中是无类型的，必须仍然可以直接赋值给 string。
+var _ = []string{IdP_Lenovo, IdP_Meizu, IdP_Coolpad, IdP_Nubia, IdP_Juefeng}
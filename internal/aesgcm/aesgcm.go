@@ -0,0 +1,70 @@
+// Package aesgcm 实现了 Token 中加密字段所使用的 AES-256-GCM 加解密。
+//
+// 世游服务端和 SDK 约定：密钥由 Secret Key 经 SHA-256 派生，密文格式为 base64(nonce || ciphertext)，其中 nonce 为 12 字节。
+package aesgcm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// NonceSize 是 nonce 的长度。
+const NonceSize = 12
+
+// deriveKey 从 Secret Key 派生 AES-256 密钥（32 字节）。
+// 使用 SHA-256 哈希，输出恰好为 32 字节。
+func deriveKey(secretKey []byte) []byte {
+	hash := sha256.Sum256(secretKey)
+	return hash[:]
+}
+
+func newGCM(secretKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveKey(secretKey))
+	if err != nil {
+		return nil, fmt.Errorf("aes cipher error: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("gcm error: %w", err)
+	}
+	return gcm, nil
+}
+
+// Decrypt 使用 AES-256-GCM 解密 base64 编码的密文。
+func Decrypt(secretKey []byte, encoded string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("base64 decode error: %w", err)
+	}
+	gcm, err := newGCM(secretKey)
+	if err != nil {
+		return "", err
+	}
+	nonceSize := gcm.NonceSize()
+	if len(data) < nonceSize {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("gcm decrypt error: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Encrypt 使用 AES-256-GCM 加密明文，返回 base64(nonce || ciphertext)。nonce 必须是 NonceSize 字节。
+func Encrypt(secretKey []byte, plaintext string, nonce []byte) (string, error) {
+	gcm, err := newGCM(secretKey)
+	if err != nil {
+		return "", err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return "", fmt.Errorf("invalid nonce length: %d", len(nonce))
+	}
+	ciphertext := gcm.Seal(nil, nonce, []byte(plaintext), nil)
+	result := append(append([]byte{}, nonce...), ciphertext...)
+	return base64.StdEncoding.EncodeToString(result), nil
+}
@@ -0,0 +1,36 @@
+package aesgcm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	secretKey := []byte("sk_test_secret_key_12345")
+	nonce := bytes.Repeat([]byte{1}, NonceSize)
+
+	encrypted, err := Encrypt(secretKey, "session_key", nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := Decrypt(secretKey, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted != "session_key" {
+		t.Fatalf("expected session_key, got %s", decrypted)
+	}
+	if nonce[0] != 1 || len(nonce) != NonceSize {
+		t.Fatal("expected nonce to be left untouched")
+	}
+
+	if _, err := Decrypt([]byte("sk_other"), encrypted); err == nil {
+		t.Fatal("expected error for wrong secret key")
+	}
+	if _, err := Decrypt(secretKey, "AAAA"); err == nil {
+		t.Fatal("expected error for short ciphertext")
+	}
+	if _, err := Encrypt(secretKey, "session_key", nonce[:8]); err == nil {
+		t.Fatal("expected error for invalid nonce length")
+	}
+}
@@ -0,0 +1,74 @@
+package combo
+
+// AcceptedSecretKey 是在 Secret Key 轮换期间仍然被接受的密钥。
+type AcceptedSecretKey struct {
+	// KeyId 是密钥的标识，可选。
+	// 如果签名或 Token 中携带了密钥标识，则只会使用标识匹配的密钥进行验证。
+	KeyId string
+
+	// SecretKey 是密钥本身，必须以 sk_ 开头。
+	SecretKey SecretKey
+}
+
+// KeyUsage 表示密钥被用于验证哪一类数据。
+type KeyUsage string
+
+const (
+	// 验证世游服务端发送的 HTTP 请求的签名。
+	KeyUsage_Signature KeyUsage = "signature"
+
+	// 验证世游服务端颁发的 Token（Identity Token、Ad Token）。
+	KeyUsage_Token KeyUsage = "token"
+
+	// 解密 Token 中的加密字段，例如 weixin_session_key。
+	KeyUsage_Decryption KeyUsage = "decryption"
+)
+
+// KeyMatchFunc 在入站数据被某个密钥验证通过时调用。
+//
+// keyId 是匹配的密钥的标识，primary 表示匹配的是否是 Config.SecretKey。
+// 游戏侧可以据此上报监控指标，确认旧密钥不再被使用之后，再将其从 Config.AcceptedSecretKeys 中移除。
+type KeyMatchFunc func(usage KeyUsage, keyId string, primary bool)
+
+type keyEntry struct {
+	id      string
+	key     SecretKey
+	primary bool
+}
+
+// keyring 包含了当前所有有效的密钥。第一个密钥总是 Config.SecretKey。
+type keyring struct {
+	keys    []keyEntry
+	onMatch KeyMatchFunc
+}
+
+func newKeyring(cfg Config) keyring {
+	keys := []keyEntry{{id: cfg.SecretKeyId, key: cfg.SecretKey, primary: true}}
+	for _, k := range cfg.AcceptedSecretKeys {
+		keys = append(keys, keyEntry{id: k.KeyId, key: k.SecretKey})
+	}
+	return keyring{
+		keys:    keys,
+		onMatch: cfg.OnKeyMatch,
+	}
+}
+
+// candidates 返回可用于验证的密钥。如果 keyId 不为空，则只返回标识匹配的密钥。
+func (k keyring) candidates(keyId string) []keyEntry {
+	if keyId == "" {
+		return k.keys
+	}
+	var keys []keyEntry
+	for _, entry := range k.keys {
+		if entry.id == keyId {
+			keys = append(keys, entry)
+		}
+	}
+	return keys
+}
+
+func (k keyring) matched(usage KeyUsage, entry keyEntry) {
+	if k.onMatch != nil {
+		k.onMatch(usage, entry.id, entry.primary)
+	}
+}
@@ -0,0 +1,169 @@
+package combo
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type keyMatch struct {
+	usage   KeyUsage
+	keyId   string
+	primary bool
+}
+
+func newRotatingConfig(matches *[]keyMatch) Config {
+	cfg := newTestConfig()
+	cfg.SecretKey = SecretKey("sk_new_secret_key")
+	cfg.SecretKeyId = "k2"
+	cfg.AcceptedSecretKeys = []AcceptedSecretKey{
+		{KeyId: "k1", SecretKey: SecretKey(testSecretKey)},
+	}
+	cfg.OnKeyMatch = func(usage KeyUsage, keyId string, primary bool) {
+		*matches = append(*matches, keyMatch{usage, keyId, primary})
+	}
+	return cfg
+}
+
+func TestConfigValidateAcceptedSecretKeys(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.AcceptedSecretKeys = []AcceptedSecretKey{{SecretKey: SecretKey("bad_key")}}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "must start with sk_") {
+		t.Fatalf("expected invalid key error, got %v", err)
+	}
+
+	cfg = newTestConfig()
+	cfg.SecretKeyId = "k1"
+	cfg.AcceptedSecretKeys = []AcceptedSecretKey{{KeyId: "k1", SecretKey: SecretKey("sk_old")}}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "duplicate KeyId") {
+		t.Fatalf("expected duplicate key id error, got %v", err)
+	}
+}
+
+func TestAuthHttpAcceptsPreviousKey(t *testing.T) {
+	var matches []keyMatch
+	signer := newHttpSigner(newRotatingConfig(&matches))
+
+	// The old signer doesn't know about key ids.
+	oldSigner := &httpSigner{game: testGameId, signingKey: SecretKey(testSecretKey)}
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/test", bytes.NewBufferString(`{}`))
+	_ = oldSigner.SignHttp(req, time.Now())
+
+	auth, err := signer.authenticate(req, time.Now())
+	if err != nil {
+		t.Fatalf("expected previous key to be accepted: %v", err)
+	}
+	if auth.keyId != "k1" {
+		t.Fatalf("expected matched key k1, got %s", auth.keyId)
+	}
+	if len(matches) != 1 || matches[0] != (keyMatch{KeyUsage_Signature, "k1", false}) {
+		t.Fatalf("unexpected key matches: %v", matches)
+	}
+}
+
+func TestSignHttpUsesPrimaryKeyWithKeyId(t *testing.T) {
+	var matches []keyMatch
+	signer := newHttpSigner(newRotatingConfig(&matches))
+
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/test", bytes.NewBufferString(`{}`))
+	_ = signer.SignHttp(req, time.Now())
+	if !strings.Contains(req.Header.Get(authorizationHeader), "KeyId=k2") {
+		t.Fatalf("expected KeyId in authorization header, got %s", req.Header.Get(authorizationHeader))
+	}
+
+	auth, err := signer.authenticate(req, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if auth.keyId != "k2" {
+		t.Fatalf("expected matched key k2, got %s", auth.keyId)
+	}
+	if len(matches) != 1 || !matches[0].primary {
+		t.Fatalf("expected primary key match, got %v", matches)
+	}
+}
+
+func TestAuthHttpUnknownKeyId(t *testing.T) {
+	var matches []keyMatch
+	signer := newHttpSigner(newRotatingConfig(&matches))
+
+	other := &httpSigner{game: testGameId, signingKey: SecretKey(testSecretKey), keyId: "k0"}
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/test", bytes.NewBufferString(`{}`))
+	_ = other.SignHttp(req, time.Now())
+
+	_, err := signer.authenticate(req, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "unknown key id") {
+		t.Fatalf("expected unknown key id error, got %v", err)
+	}
+}
+
+func TestVerifyIdentityTokenWithPreviousKey(t *testing.T) {
+	var matches []keyMatch
+	v, err := NewTokenVerifier(newRotatingConfig(&matches))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := &identityClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(testEndpoint),
+			Subject:   "combo_123",
+			Audience:  jwt.ClaimStrings{string(testGameId)},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		Scope:            "auth",
+		IdP:              "minigame_weixin",
+		WeixinSessionKey: encryptSessionKey(t, SecretKey(testSecretKey), "session_key_value"),
+	}
+	payload, err := v.VerifyIdentityToken(signToken(t, claims))
+	if err != nil {
+		t.Fatalf("expected token signed by previous key to be accepted: %v", err)
+	}
+	if payload.WeixinSessionKey != "session_key_value" {
+		t.Fatalf("unexpected WeixinSessionKey: %q", payload.WeixinSessionKey)
+	}
+	want := []keyMatch{
+		{KeyUsage_Token, "k1", false},
+		{KeyUsage_Decryption, "k1", false},
+	}
+	if len(matches) != len(want) || matches[0] != want[0] || matches[1] != want[1] {
+		t.Fatalf("expected key matches %v, got %v", want, matches)
+	}
+}
+
+func TestVerifyIdentityTokenWithKeyId(t *testing.T) {
+	var matches []keyMatch
+	v, err := NewTokenVerifier(newRotatingConfig(&matches))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := &identityClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(testEndpoint),
+			Subject:   "combo_123",
+			Audience:  jwt.ClaimStrings{string(testGameId)},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Scope: "auth",
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = "k2"
+	tokenString, _ := token.SignedString([]byte("sk_new_secret_key"))
+	if _, err := v.VerifyIdentityToken(tokenString); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A token claiming the wrong key id is rejected even though the key is accepted.
+	token = jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = "k2"
+	tokenString, _ = token.SignedString([]byte(testSecretKey))
+	if _, err := v.VerifyIdentityToken(tokenString); err == nil {
+		t.Fatal("expected error for mismatched key id")
+	}
+}
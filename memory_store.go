@@ -0,0 +1,28 @@
+package combo
+
+// MemoryStoreOption 是函数式风格的可选项，用于创建基于 Memory 的各类 Store，
+// 例如 NewMemoryReplayStore、NewMemoryRevocationStore。
+type MemoryStoreOption func(*memoryStoreOptions)
+
+type memoryStoreOptions struct {
+	clock Clock
+}
+
+// WithMemoryStoreClock 用于指定 Memory Store 判断数据是否过期时使用的 Clock。
+//
+// 如果不指定，则默认为 SystemClock。在测试中可以和 Config.Clock 指定同一个 Clock，以便冻结整个 SDK 的时间。
+func WithMemoryStoreClock(clock Clock) MemoryStoreOption {
+	return func(o *memoryStoreOptions) {
+		if clock != nil {
+			o.clock = clock
+		}
+	}
+}
+
+func newMemoryStoreOptions(options []MemoryStoreOption) memoryStoreOptions {
+	o := memoryStoreOptions{clock: SystemClock}
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
@@ -20,6 +20,8 @@ type SecretKey []byte
 type Platform string
 
 // Identity Provider (IdP) 是世游定义的用户身份提供方，俗称账号系统。
+//
+// 世游服务端可能会新增 IdP，所以 Token 中的 IdP 不一定是 SDK 已知的值。参见 IdP.Valid 和 IdP.Info。
 type IdP string
 
 const (
@@ -109,6 +111,9 @@ const (
 	IdP_Maowo IdP = "maowo"
 
 	// 联想
+	//
+	// 注意：IdP_Lenovo、IdP_Meizu、IdP_Coolpad、IdP_Nubia 和 IdP_Juefeng 在旧版本中是无类型的字符串常量，
+	// 为了不破坏把它们当作 string 使用的代码，这里保持无类型。
 	IdP_Lenovo = "lenovo"
 
 	// 魅族
@@ -124,27 +129,48 @@ const (
 	IdP_Juefeng = "juefeng"
 
 	// 魅拓游戏
-	Idp_Meituo IdP = "meituo"
+	IdP_Meituo IdP = "meituo"
 
 	// 微信小游戏
-	Idp_MinigameWeixin IdP = "minigame_weixin"
+	IdP_MinigameWeixin IdP = "minigame_weixin"
 
 	// 抖音小游戏
-	Idp_MinigameDouyin IdP = "minigame_douyin"
+	IdP_MinigameDouyin IdP = "minigame_douyin"
 
 	// MuMu 模拟器
-	Idp_Mumu IdP = "mumu"
+	IdP_Mumu IdP = "mumu"
 
 	// 虎牙游戏
-	Idp_Huya IdP = "huya"
+	IdP_Huya IdP = "huya"
 
 	// 好游快爆
-	Idp_Hykb IdP = "hykb"
+	IdP_Hykb IdP = "hykb"
 
 	// 233 乐园
 	IdP_233 IdP = "233"
 )
 
+// 以下名称与其他 IdP 常量的命名风格不一致，仅为兼容旧版本保留。
+const (
+	// Deprecated: 使用 IdP_Meituo。
+	Idp_Meituo = IdP_Meituo
+
+	// Deprecated: 使用 IdP_MinigameWeixin。
+	Idp_MinigameWeixin = IdP_MinigameWeixin
+
+	// Deprecated: 使用 IdP_MinigameDouyin。
+	Idp_MinigameDouyin = IdP_MinigameDouyin
+
+	// Deprecated: 使用 IdP_Mumu。
+	Idp_Mumu = IdP_Mumu
+
+	// Deprecated: 使用 IdP_Huya。
+	Idp_Huya = IdP_Huya
+
+	// Deprecated: 使用 IdP_Hykb。
+	Idp_Hykb = IdP_Hykb
+)
+
 func (e Endpoint) url(api string) string {
 	return fmt.Sprintf("%s/v1/server/%s", e, api)
 }
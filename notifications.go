@@ -6,8 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
-	"time"
 )
 
 const (
@@ -20,20 +18,17 @@ const (
 // 游戏侧需要将此 Handler 注册到游戏的 HTTP 服务中。
 //
 // 注意：注册 Handler 时，应当使用 HTTP POST。
-func NewNotificationHandler(cfg Config, listener NotificationListener) (http.Handler, error) {
+func NewNotificationHandler(cfg Config, listener NotificationListener, options ...HandlerOption) (http.Handler, error) {
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
 	if listener == nil {
 		return nil, errors.New("missing required listener")
 	}
-	return &notificationHandler{
-		signer: httpSigner{
-			game:       cfg.GameId,
-			signingKey: cfg.SecretKey,
-		},
+	handler := &notificationHandler{
 		listener: listener,
-	}, nil
+	}
+	return newSignatureHandler(cfg, handler, PlainTextErrorWriter, options), nil
 }
 
 // 每次通知的唯一 ID。游戏侧可用于日志记录、调试、问题排查。
@@ -132,24 +127,10 @@ type notificationRequestBody struct {
 }
 
 type notificationHandler struct {
-	signer   httpSigner
 	listener NotificationListener
 }
 
 func (h *notificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "please use POST", http.StatusMethodNotAllowed)
-		return
-	}
-	contentType := r.Header.Get("Content-Type")
-	if !strings.HasPrefix(contentType, "application/json") {
-		http.Error(w, "please use application/json", http.StatusUnsupportedMediaType)
-		return
-	}
-	if err := h.signer.AuthHttp(r, time.Now()); err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		return
-	}
 	var body notificationRequestBody
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -84,6 +84,9 @@ func TestNotificationHandlerMethodNotAllowed(t *testing.T) {
 	if rec.Code != http.StatusMethodNotAllowed {
 		t.Fatalf("expected %d, got %d", http.StatusMethodNotAllowed, rec.Code)
 	}
+	if body := rec.Body.String(); body != "please use POST\n" {
+		t.Fatalf("unexpected body: %q", body)
+	}
 }
 
 func TestNotificationHandlerWrongContentType(t *testing.T) {
@@ -98,6 +101,9 @@ func TestNotificationHandlerWrongContentType(t *testing.T) {
 	if rec.Code != http.StatusUnsupportedMediaType {
 		t.Fatalf("expected %d, got %d", http.StatusUnsupportedMediaType, rec.Code)
 	}
+	if body := rec.Body.String(); body != "please use application/json\n" {
+		t.Fatalf("unexpected body: %q", body)
+	}
 }
 
 func TestNotificationHandlerUnauthorized(t *testing.T) {
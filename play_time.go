@@ -0,0 +1,181 @@
+package combo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// chinaTime 是北京时间 (Asia/Shanghai)。中国自 1991 年起不再实行夏令时，所以这里使用固定时区，以免依赖系统的 tzdata。
+var chinaTime = time.FixedZone("Asia/Shanghai", 8*60*60)
+
+const (
+	// 未成年人每天允许游戏的时间段，北京时间 20:00 - 21:00。
+	minorWindowStartHour = 20
+	minorWindowEndHour   = 21
+
+	// 未实名认证用户（游客）的默认累计游戏时长上限。
+	defaultGuestPlayTime = time.Hour
+)
+
+// PlayTimeReason 是 PlayTimePolicy 判定结果的原因。
+type PlayTimeReason string
+
+const (
+	// 用户已成年，游戏时间不受限制。
+	PlayTime_Adult PlayTimeReason = "adult"
+
+	// 用户未成年，当前处于允许游戏的时间段内。
+	PlayTime_MinorInWindow PlayTimeReason = "minor_in_window"
+
+	// 用户未成年，当前不在允许游戏的时间段内。
+	PlayTime_MinorOutsideWindow PlayTimeReason = "minor_outside_window"
+
+	// 用户未实名认证，累计游戏时长未超过上限。
+	PlayTime_GuestWithinLimit PlayTimeReason = "guest_within_limit"
+
+	// 用户未实名认证，累计游戏时长已超过上限。
+	PlayTime_GuestLimitExceeded PlayTimeReason = "guest_limit_exceeded"
+)
+
+// PlayTimeDecision 是 PlayTimePolicy 的判定结果。
+type PlayTimeDecision struct {
+	// 是否允许游戏。
+	Allowed bool
+
+	// 判定结果的原因。
+	Reason PlayTimeReason
+
+	// 当前允许游戏的时间段的结束时间，游戏侧应当在此时强制用户下线。
+	// 仅在 Allowed 为 true 时有值。成年用户不受限制，此时为零值。
+	WindowEnd time.Time
+
+	// 下一个允许游戏的时间段的开始时间。仅在未成年用户当前不在允许游戏的时间段内时有值。
+	NextWindowStart time.Time
+}
+
+// PlayTimePolicyConfig 包含了创建 PlayTimePolicy 时的配置项。
+type PlayTimePolicyConfig struct {
+	Holidays      *HolidayCalendar // 法定节假日，未成年人在法定节假日也可以游戏。如果不指定，则只有周五、周六、周日允许游戏
+	GuestPlayTime time.Duration    // 未实名认证用户的累计游戏时长上限，如果不指定，则默认为 1 小时
+}
+
+// PlayTimePolicy 用于根据防沉迷规定判定用户当前是否允许游戏。
+//
+// 规则如下（时间均为北京时间）：
+//   - 成年用户 (Age >= 18) 不受限制。
+//   - 未成年用户仅可在周五、周六、周日和法定节假日的 20:00 - 21:00 游戏。
+//   - 未实名认证用户 (Age == 0) 的累计游戏时长不能超过 GuestPlayTime。
+//
+// 注意：Combo SDK 默认由世游服务端处理防沉迷，只有在游戏侧需要自行处理防沉迷的特殊场景下才需要使用 PlayTimePolicy。
+type PlayTimePolicy struct {
+	holidays      *HolidayCalendar
+	guestPlayTime time.Duration
+}
+
+// NewPlayTimePolicy 创建一个 PlayTimePolicy。
+func NewPlayTimePolicy(cfg PlayTimePolicyConfig) *PlayTimePolicy {
+	if cfg.GuestPlayTime <= 0 {
+		cfg.GuestPlayTime = defaultGuestPlayTime
+	}
+	return &PlayTimePolicy{
+		holidays:      cfg.Holidays,
+		guestPlayTime: cfg.GuestPlayTime,
+	}
+}
+
+// Check 判定用户在 now 时刻是否允许游戏。
+//
+// guestPlayed 是未实名认证用户已经累计的游戏时长，由游戏侧自行统计，仅在 identity.Age 为 0 时使用。
+func (p *PlayTimePolicy) Check(identity *IdentityPayload, now time.Time, guestPlayed time.Duration) PlayTimeDecision {
+	now = now.In(chinaTime)
+	switch {
+	case identity.Age >= 18:
+		return PlayTimeDecision{Allowed: true, Reason: PlayTime_Adult}
+	case identity.Age <= 0:
+		if guestPlayed >= p.guestPlayTime {
+			return PlayTimeDecision{Reason: PlayTime_GuestLimitExceeded}
+		}
+		return PlayTimeDecision{
+			Allowed:   true,
+			Reason:    PlayTime_GuestWithinLimit,
+			WindowEnd: now.Add(p.guestPlayTime - guestPlayed),
+		}
+	}
+	start, end := minorWindow(now)
+	if p.isPlayDay(now) && !now.Before(start) && now.Before(end) {
+		return PlayTimeDecision{Allowed: true, Reason: PlayTime_MinorInWindow, WindowEnd: end}
+	}
+	return PlayTimeDecision{Reason: PlayTime_MinorOutsideWindow, NextWindowStart: p.nextMinorWindow(now)}
+}
+
+// isPlayDay 判断 t 所在的日期是否允许未成年人游戏。
+func (p *PlayTimePolicy) isPlayDay(t time.Time) bool {
+	switch t.Weekday() {
+	case time.Friday, time.Saturday, time.Sunday:
+		return true
+	}
+	return p.holidays.IsHoliday(t)
+}
+
+// nextMinorWindow 返回 now 之后下一个允许未成年人游戏的时间段的开始时间。
+func (p *PlayTimePolicy) nextMinorWindow(now time.Time) time.Time {
+	// 每周五都允许游戏，所以最多只需要向后查找 7 天。
+	for i := 0; i <= 7; i++ {
+		day := now.AddDate(0, 0, i)
+		start, _ := minorWindow(day)
+		if p.isPlayDay(day) && start.After(now) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// minorWindow 返回 t 所在日期的未成年人游戏时间段。
+func minorWindow(t time.Time) (time.Time, time.Time) {
+	year, month, day := t.Date()
+	start := time.Date(year, month, day, minorWindowStartHour, 0, 0, 0, chinaTime)
+	end := time.Date(year, month, day, minorWindowEndHour, 0, 0, 0, chinaTime)
+	return start, end
+}
+
+// HolidayCalendar 是法定节假日日历。
+type HolidayCalendar struct {
+	dates map[string]bool
+}
+
+// ParseHolidayCalendar 解析法定节假日日历。data 是 JSON 格式的日期数组，日期格式为 "2006-01-02"，例如：
+//
+//	["2024-10-01", "2024-10-02", "2024-10-03"]
+func ParseHolidayCalendar(data []byte) (*HolidayCalendar, error) {
+	var dates []string
+	if err := json.Unmarshal(data, &dates); err != nil {
+		return nil, fmt.Errorf("error parsing holiday calendar: %w", err)
+	}
+	c := &HolidayCalendar{dates: make(map[string]bool, len(dates))}
+	for _, date := range dates {
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			return nil, fmt.Errorf("invalid holiday date %q: %w", date, err)
+		}
+		c.dates[date] = true
+	}
+	return c, nil
+}
+
+// LoadHolidayCalendar 从文件中加载法定节假日日历。文件格式参见 ParseHolidayCalendar。
+func LoadHolidayCalendar(path string) (*HolidayCalendar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseHolidayCalendar(data)
+}
+
+// IsHoliday 判断 t 所在的日期（北京时间）是否为法定节假日。
+func (c *HolidayCalendar) IsHoliday(t time.Time) bool {
+	if c == nil {
+		return false
+	}
+	return c.dates[t.In(chinaTime).Format(time.DateOnly)]
+}
@@ -0,0 +1,133 @@
+package combo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func chinaDate(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, chinaTime)
+}
+
+func TestPlayTimePolicyAdult(t *testing.T) {
+	policy := NewPlayTimePolicy(PlayTimePolicyConfig{})
+	d := policy.Check(&IdentityPayload{Age: 18}, chinaDate(2024, 1, 15, 3, 0), 0)
+	if !d.Allowed || d.Reason != PlayTime_Adult || !d.WindowEnd.IsZero() {
+		t.Fatalf("unexpected decision: %+v", d)
+	}
+}
+
+func TestPlayTimePolicyMinor(t *testing.T) {
+	holidays, err := ParseHolidayCalendar([]byte(`["2024-10-01"]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := NewPlayTimePolicy(PlayTimePolicyConfig{Holidays: holidays})
+	minor := &IdentityPayload{Age: 16}
+
+	tests := []struct {
+		name            string
+		now             time.Time
+		wantAllowed     bool
+		wantWindowEnd   time.Time
+		wantNextWindows time.Time
+	}{
+		{
+			name:          "friday in window",
+			now:           chinaDate(2024, 1, 19, 20, 30),
+			wantAllowed:   true,
+			wantWindowEnd: chinaDate(2024, 1, 19, 21, 0),
+		},
+		{
+			name:            "friday before window",
+			now:             chinaDate(2024, 1, 19, 19, 59),
+			wantNextWindows: chinaDate(2024, 1, 19, 20, 0),
+		},
+		{
+			name:            "sunday after window",
+			now:             chinaDate(2024, 1, 21, 21, 0),
+			wantNextWindows: chinaDate(2024, 1, 26, 20, 0),
+		},
+		{
+			name:            "monday in window hours",
+			now:             chinaDate(2024, 1, 15, 20, 30),
+			wantNextWindows: chinaDate(2024, 1, 19, 20, 0),
+		},
+		{
+			name:          "holiday on tuesday",
+			now:           chinaDate(2024, 10, 1, 20, 0),
+			wantAllowed:   true,
+			wantWindowEnd: chinaDate(2024, 10, 1, 21, 0),
+		},
+		{
+			name:          "utc input is converted to china time",
+			now:           time.Date(2024, 1, 20, 12, 15, 0, 0, time.UTC),
+			wantAllowed:   true,
+			wantWindowEnd: chinaDate(2024, 1, 20, 21, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := policy.Check(minor, tt.now, 0)
+			if d.Allowed != tt.wantAllowed {
+				t.Fatalf("expected allowed=%v, got %+v", tt.wantAllowed, d)
+			}
+			if tt.wantAllowed && d.Reason != PlayTime_MinorInWindow {
+				t.Fatalf("expected %s, got %s", PlayTime_MinorInWindow, d.Reason)
+			}
+			if !tt.wantAllowed && d.Reason != PlayTime_MinorOutsideWindow {
+				t.Fatalf("expected %s, got %s", PlayTime_MinorOutsideWindow, d.Reason)
+			}
+			if !d.WindowEnd.Equal(tt.wantWindowEnd) {
+				t.Fatalf("expected window end %v, got %v", tt.wantWindowEnd, d.WindowEnd)
+			}
+			if !d.NextWindowStart.Equal(tt.wantNextWindows) {
+				t.Fatalf("expected next window %v, got %v", tt.wantNextWindows, d.NextWindowStart)
+			}
+		})
+	}
+}
+
+func TestPlayTimePolicyGuest(t *testing.T) {
+	policy := NewPlayTimePolicy(PlayTimePolicyConfig{GuestPlayTime: 30 * time.Minute})
+	now := chinaDate(2024, 1, 15, 10, 0)
+
+	d := policy.Check(&IdentityPayload{}, now, 10*time.Minute)
+	if !d.Allowed || d.Reason != PlayTime_GuestWithinLimit || !d.WindowEnd.Equal(now.Add(20*time.Minute)) {
+		t.Fatalf("unexpected decision: %+v", d)
+	}
+	d = policy.Check(&IdentityPayload{}, now, 30*time.Minute)
+	if d.Allowed || d.Reason != PlayTime_GuestLimitExceeded {
+		t.Fatalf("unexpected decision: %+v", d)
+	}
+}
+
+func TestHolidayCalendar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "holidays.json")
+	if err := os.WriteFile(path, []byte(`["2024-10-01", "2024-10-02"]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadHolidayCalendar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.IsHoliday(chinaDate(2024, 10, 2, 23, 59)) {
+		t.Fatal("expected 2024-10-02 to be a holiday")
+	}
+	// 2024-09-30 17:00 UTC is already 2024-10-01 in China.
+	if !c.IsHoliday(time.Date(2024, 9, 30, 17, 0, 0, 0, time.UTC)) {
+		t.Fatal("expected holiday lookup to use china time")
+	}
+	if c.IsHoliday(chinaDate(2024, 10, 3, 12, 0)) {
+		t.Fatal("expected 2024-10-03 not to be a holiday")
+	}
+
+	if _, err := ParseHolidayCalendar([]byte(`["2024/10/01"]`)); err == nil {
+		t.Fatal("expected error for malformed date")
+	}
+	if _, err := LoadHolidayCalendar(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
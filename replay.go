@@ -0,0 +1,130 @@
+package combo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrReplayedRequest 表示请求的签名已经被使用过，即请求被重放了。
+var ErrReplayedRequest = errors.New("replayed request")
+
+// WithReplayStore 用于开启防重放校验。
+//
+// 开启后，每个通过签名校验的请求的签名都会被记录在 store 中，直到签名时间超出允许的时间误差范围。
+// 在此期间，携带相同签名的请求会被拒绝，并且不会调用游戏侧的 listener 或 next handler。
+func WithReplayStore(store ReplayStore) HandlerOption {
+	return func(h *signatureHandler) {
+		h.replayStore = store
+	}
+}
+
+// ReplayStore 是一个用于记录已使用过的请求签名的接口，用于防止请求被重放。
+//
+// Combo SDK 内置了 Redis 和 Memory 两种实现，可分别通过 NewMemoryReplayStore() 和 NewRedisReplayStore() 创建。
+//
+// 游戏侧也可以选择自行实现 ReplayStore 接口。
+type ReplayStore interface {
+	// Add 用于原子性地记录 key，key 在 ttl 之后过期。
+	// 如果 key 不存在，则存储 key 并返回 true。如果 key 已存在，则返回 false。
+	Add(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// NewMemoryReplayStore 创建一个基于 Memory 的 ReplayStore 实现。
+//
+// 数据仅在内存中存储，重启服务后数据会丢失。过期的数据会被定期清理。
+//
+// 注意：如果游戏服务部署了多个实例，那么每个实例只能识别出发送给自己的重放请求。
+func NewMemoryReplayStore(options ...MemoryStoreOption) ReplayStore {
+	return &memoryReplayStore{
+		clock:  newMemoryStoreOptions(options).clock,
+		expiry: make(map[string]time.Time),
+	}
+}
+
+// NewRedisReplayStore 创建一个基于 Redis 的 ReplayStore 实现。
+//
+// 数据会存储在 Redis 中，可以在多个游戏服务实例之间共享，并且到期自动清理。推荐生产环境使用。
+func NewRedisReplayStore(cfg RedisReplayStoreConfig) ReplayStore {
+	if cfg.Client == nil {
+		panic("missing required cfg.Client")
+	}
+	return &redisReplayStore{
+		client: cfg.Client,
+		prefix: cfg.Prefix,
+	}
+}
+
+// RedisReplayStoreConfig 包含了创建基于 Redis 的 ReplayStore 时所必需的配置项。
+type RedisReplayStoreConfig struct {
+	Client redis.Cmdable // Redis 客户端。这里不假设 Redis 的运维部署方式。可以是 redis.Client 或者 redis.ClusterClient，由游戏侧自行创建和配置。
+	Prefix string        // Key 的前缀，如果不指定，则默认为空字符串。
+}
+
+// memoryReplayStoreSweepInterval 是 memoryReplayStore 清理过期数据的最小时间间隔。
+const memoryReplayStoreSweepInterval = time.Minute
+
+type memoryReplayStore struct {
+	mu        sync.Mutex
+	clock     Clock
+	expiry    map[string]time.Time
+	lastSweep time.Time
+}
+
+// Add implements ReplayStore.
+func (s *memoryReplayStore) Add(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	if now.Sub(s.lastSweep) >= memoryReplayStoreSweepInterval {
+		for k, expiresAt := range s.expiry {
+			if !now.Before(expiresAt) {
+				delete(s.expiry, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	if expiresAt, ok := s.expiry[key]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+	s.expiry[key] = now.Add(ttl)
+	return true, nil
+}
+
+type redisReplayStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// Add implements ReplayStore.
+func (s *redisReplayStore) Add(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, s.prefix+key, 1, ttl).Result()
+}
+
+// checkReplay 将通过签名校验的请求的签名记录到 replayStore 中。
+// 如果签名已经被使用过，则返回 Reason 为 AuthFailure_Replayed 的 *AuthError，它可以被 errors.Is 识别为 ErrReplayedRequest。
+func (h *signatureHandler) checkReplay(ctx context.Context, auth *authorization, now time.Time) error {
+	if h.replayStore == nil {
+		return nil
+	}
+	// 签名只在签名时间前后 maxSkew 的范围内有效，超出范围的重放请求会被时间校验拒绝，所以无需继续记录。
+	ttl := auth.timestamp.Add(h.signer.maxSkew()).Sub(now)
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	added, err := h.replayStore.Add(ctx, string(auth.game)+":"+auth.signature, ttl)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return &AuthError{
+			Reason:  AuthFailure_Replayed,
+			message: ErrReplayedRequest.Error(),
+			err:     ErrReplayedRequest,
+		}
+	}
+	return nil
+}
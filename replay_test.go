@@ -0,0 +1,131 @@
+package combo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMemoryReplayStore(t *testing.T) {
+	store := NewMemoryReplayStore()
+	ctx := context.Background()
+
+	added, err := store.Add(ctx, "key", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !added {
+		t.Fatal("expected first Add to succeed")
+	}
+	added, err = store.Add(ctx, "key", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if added {
+		t.Fatal("expected second Add to report duplicate")
+	}
+}
+
+func TestMemoryReplayStoreExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryReplayStore(WithMemoryStoreClock(ClockFunc(func() time.Time { return now })))
+	ctx := context.Background()
+
+	if added, _ := store.Add(ctx, "key", time.Minute); !added {
+		t.Fatal("expected first Add to succeed")
+	}
+	now = now.Add(59 * time.Second)
+	if added, _ := store.Add(ctx, "key", time.Minute); added {
+		t.Fatal("expected Add to report duplicate before key expired")
+	}
+	now = now.Add(time.Second)
+	if added, _ := store.Add(ctx, "key", time.Minute); !added {
+		t.Fatal("expected Add to succeed after key expired")
+	}
+}
+
+func TestNewRedisReplayStorePanicsWithoutClient(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected panic for missing client")
+		}
+	}()
+	NewRedisReplayStore(RedisReplayStoreConfig{})
+}
+
+func TestRedisReplayStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := NewRedisReplayStore(RedisReplayStoreConfig{
+		Client: client,
+		Prefix: "replay:",
+	})
+	ctx := context.Background()
+
+	if added, err := store.Add(ctx, "key", time.Minute); err != nil || !added {
+		t.Fatalf("expected first Add to succeed, got added=%v, err=%v", added, err)
+	}
+	if added, err := store.Add(ctx, "key", time.Minute); err != nil || added {
+		t.Fatalf("expected second Add to report duplicate, got added=%v, err=%v", added, err)
+	}
+	if !mr.Exists("replay:key") {
+		t.Fatal("expected key to be stored with prefix")
+	}
+
+	mr.FastForward(2 * time.Minute)
+	if added, err := store.Add(ctx, "key", time.Minute); err != nil || !added {
+		t.Fatalf("expected Add to succeed after key expired, got added=%v, err=%v", added, err)
+	}
+}
+
+func TestRequireSignatureRejectsReplayedRequest(t *testing.T) {
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+	handler, signer := newTestSignatureHandler(t, next, WithReplayStore(NewMemoryReplayStore()))
+
+	signingTime := time.Now()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest(t, signer, signingTime, []byte(`{}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest(t, signer, signingTime, []byte(`{}`)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+	if calls != 1 {
+		t.Fatalf("expected next handler to be called once, got %d", calls)
+	}
+}
+
+func TestGmHandlerRejectsReplayedRequest(t *testing.T) {
+	listener := &mockGmListener{resp: map[string]string{"ok": "true"}}
+	handler, err := NewGmHandler(newTestConfig(), listener, WithReplayStore(NewMemoryReplayStore()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &httpSigner{game: testGameId, signingKey: SecretKey(testSecretKey)}
+	body := validGmBody(t)
+	signingTime := time.Now()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest(t, signer, signingTime, body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest(t, signer, signingTime, body))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d: %s", http.StatusUnauthorized, rec.Code, rec.Body.String())
+	}
+}
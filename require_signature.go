@@ -0,0 +1,242 @@
+package combo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RequireSignature 创建一个 http.Handler 中间件，用于校验世游服务端发送的 HTTP 请求的签名。
+//
+// 游戏侧可以用它来保护自行实现的、供世游服务端回调的 HTTP 接口。
+// 请求校验通过后，SignatureInfo 会被写入请求的 context 中，next 可通过 SignatureInfoFromContext 获取。
+//
+// 校验不通过时，默认使用 PlainTextErrorWriter 写入错误响应。可以通过 WithErrorWriter 指定其他的错误响应格式。
+//
+// 注意：世游服务端发送的请求总是使用 HTTP POST 和 application/json。
+func RequireSignature(cfg Config, next http.Handler, options ...HandlerOption) (http.Handler, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if next == nil {
+		return nil, errors.New("missing required next handler")
+	}
+	return newSignatureHandler(cfg, next, PlainTextErrorWriter, options), nil
+}
+
+// HandlerOption 是函数式风格的的可选项，用于创建处理世游服务端请求的 http.Handler。
+//
+// 适用于 NewNotificationHandler、NewGmHandler 和 RequireSignature。
+type HandlerOption func(*signatureHandler)
+
+// WithErrorWriter 用于指定请求校验不通过时，写入错误响应的方式。如果 writer 为 nil，则忽略此选项。
+func WithErrorWriter(writer ErrorWriter) HandlerOption {
+	return func(h *signatureHandler) {
+		if writer != nil {
+			h.errorWriter = writer
+		}
+	}
+}
+
+// WithMaxClockSkew 用于指定请求的签名时间和当前时间之间允许的最大误差。
+//
+// 如果不指定，则默认为 5 分钟。更严格的安全策略可以指定更小的值，但需要确保游戏服务器的系统时间准确。
+func WithMaxClockSkew(d time.Duration) HandlerOption {
+	return func(h *signatureHandler) {
+		h.signer.maxTimeDiff = d
+	}
+}
+
+// WithMaxBodySize 用于指定请求 body 的最大长度，单位为字节。
+//
+// 签名校验需要读取完整的 body，所以 body 超出最大长度的请求会在签名校验之前被拒绝，返回 HTTP 413。
+// 如果不指定，则默认为 4 MiB。如果 n <= 0，则不限制 body 的长度。
+func WithMaxBodySize(n int64) HandlerOption {
+	return func(h *signatureHandler) {
+		h.maxBodySize = n
+	}
+}
+
+// WithMinSignatureVersion 用于指定接受的最低签名算法版本。
+//
+// 如果不指定，则 v1 和 v2 版本的签名均会被接受。指定为 2 时，只接受覆盖了 Host 等请求头的 v2 版本的签名。
+func WithMinSignatureVersion(version int) HandlerOption {
+	return func(h *signatureHandler) {
+		h.signer.minVersion = version
+	}
+}
+
+// WithOnAuthFailure 用于指定签名验证失败时的回调函数。
+//
+// 游戏侧可以根据 AuthError.Reason 区分不同的失败原因，例如记录日志、上报监控指标、封禁可疑的来源地址。
+func WithOnAuthFailure(fn AuthFailureFunc) HandlerOption {
+	return func(h *signatureHandler) {
+		h.onAuthFailure = fn
+	}
+}
+
+// WithAuthDebug 用于开启签名验证的调试模式。
+//
+// 开启后，如果签名不正确，错误响应中会包含服务端计算签名时使用的 string to sign，便于联调时排查签名问题。
+//
+// 注意：调试模式会向请求方暴露服务端的签名计算细节，仅用于联调，不应在生产环境中开启。
+func WithAuthDebug() HandlerOption {
+	return func(h *signatureHandler) {
+		h.signer.debug = true
+	}
+}
+
+// ErrorWriter 用于在请求校验不通过时写入错误响应。
+//
+// status 是 HTTP 状态码，code 是对应的 GmError 错误类型，message 是错误描述信息。
+type ErrorWriter func(w http.ResponseWriter, status int, code GmError, message string)
+
+// PlainTextErrorWriter 以纯文本的格式写入错误响应，和 NotificationHandler 的错误响应格式一致。
+//
+// HTTP method 和 Content-Type 错误沿用 NotificationHandler 一贯的错误描述，不使用 message。
+func PlainTextErrorWriter(w http.ResponseWriter, status int, code GmError, message string) {
+	switch code {
+	case GmError_InvalidHttpMethod:
+		message = "please use POST"
+	case GmError_InvalidContentType:
+		message = "please use application/json"
+	}
+	http.Error(w, message, status)
+}
+
+// GmErrorWriter 以 JSON 的格式写入 GmErrorResponse，和 GmHandler 的错误响应格式一致。
+func GmErrorWriter(w http.ResponseWriter, status int, code GmError, message string) {
+	writeJson(w, status, GmErrorResponse{
+		Error:   code,
+		Message: message,
+	})
+}
+
+// SignatureInfo 包含了通过签名校验的 HTTP 请求的认证信息。
+type SignatureInfo struct {
+	// GameId 是请求签名中的 Game ID。
+	GameId GameId
+
+	// KeyId 是验证签名时匹配的密钥的标识。对应 Config.SecretKeyId 或 AcceptedSecretKey.KeyId。
+	KeyId string
+
+	// SigningTime 是请求签名中的签名时间。
+	SigningTime time.Time
+
+	// Version 是请求签名所使用的签名算法版本，取值为 1 或 2。
+	Version int
+
+	// SignedHeaders 是 v2 版本的签名所覆盖的请求头，名称均为小写。v1 版本的签名为空。
+	SignedHeaders []string
+}
+
+type signatureInfoKey struct{}
+
+// SignatureInfoFromContext 从 context 中获取 RequireSignature 写入的 SignatureInfo。
+//
+// 如果 context 中没有 SignatureInfo，则返回 nil, false。
+func SignatureInfoFromContext(ctx context.Context) (*SignatureInfo, bool) {
+	info, ok := ctx.Value(signatureInfoKey{}).(*SignatureInfo)
+	return info, ok
+}
+
+// defaultMaxBodySize 是请求 body 的默认最大长度。
+const defaultMaxBodySize = 4 << 20
+
+type signatureHandler struct {
+	signer        httpSigner
+	next          http.Handler
+	errorWriter   ErrorWriter
+	replayStore   ReplayStore
+	maxBodySize   int64
+	onAuthFailure AuthFailureFunc
+}
+
+func newSignatureHandler(cfg Config, next http.Handler, errorWriter ErrorWriter, options []HandlerOption) *signatureHandler {
+	h := &signatureHandler{
+		signer:      newHttpSigner(cfg),
+		next:        next,
+		errorWriter: errorWriter,
+		maxBodySize: defaultMaxBodySize,
+	}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+func (h *signatureHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.errorWriter(w, http.StatusMethodNotAllowed, GmError_InvalidHttpMethod, "Expecting POST, got "+r.Method)
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/json") {
+		h.errorWriter(w, http.StatusUnsupportedMediaType, GmError_InvalidContentType, "Expecting application/json, got "+contentType)
+		return
+	}
+	if h.maxBodySize > 0 {
+		if r.ContentLength > h.maxBodySize {
+			h.writeRequestTooLarge(w)
+			return
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, h.maxBodySize)
+		}
+	}
+	now := h.signer.now()
+	auth, err := h.signer.authenticate(r, now)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			h.writeRequestTooLarge(w)
+			return
+		}
+		h.writeAuthFailure(w, r, err)
+		return
+	}
+	if err := h.checkReplay(r.Context(), auth, now); err != nil {
+		var authErr *AuthError
+		if errors.As(err, &authErr) {
+			h.writeAuthFailure(w, r, err)
+		} else {
+			h.errorWriter(w, http.StatusInternalServerError, GmError_InternalError, "failed to check replay: "+err.Error())
+		}
+		return
+	}
+	ctx := context.WithValue(r.Context(), signatureInfoKey{}, &SignatureInfo{
+		GameId:        auth.game,
+		KeyId:         auth.keyId,
+		SigningTime:   auth.timestamp,
+		Version:       auth.version,
+		SignedHeaders: auth.signedHeaders,
+	})
+	h.next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (h *signatureHandler) writeAuthFailure(w http.ResponseWriter, r *http.Request, err error) {
+	var authErr *AuthError
+	if h.onAuthFailure != nil && errors.As(err, &authErr) {
+		h.onAuthFailure(authErr, r.RemoteAddr)
+	}
+	h.errorWriter(w, http.StatusUnauthorized, GmError_InvalidSignature, err.Error())
+}
+
+func (h *signatureHandler) writeRequestTooLarge(w http.ResponseWriter) {
+	h.errorWriter(w, http.StatusRequestEntityTooLarge, GmError_RequestTooLarge,
+		fmt.Sprintf("Request body exceeds the maximum size of %d bytes", h.maxBodySize))
+}
+
+func writeJson(w http.ResponseWriter, code int, obj any) {
+	header := w.Header()
+	header["Content-Type"] = jsonContentType
+	w.WriteHeader(code)
+	jsonBytes, _ := json.Marshal(obj)
+	if jsonBytes != nil {
+		_, _ = w.Write(jsonBytes)
+	}
+}
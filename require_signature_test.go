@@ -0,0 +1,260 @@
+package combo
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestSignatureHandler(t *testing.T, next http.Handler, options ...HandlerOption) (http.Handler, *httpSigner) {
+	t.Helper()
+	cfg := newTestConfig()
+	handler, err := RequireSignature(cfg, next, options...)
+	if err != nil {
+		t.Fatalf("failed to create signature handler: %v", err)
+	}
+	signer := &httpSigner{
+		game:       cfg.GameId,
+		signingKey: cfg.SecretKey,
+	}
+	return handler, signer
+}
+
+func signedRequest(t *testing.T, signer *httpSigner, signingTime time.Time, body []byte) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/callback", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	if err := signer.SignHttp(req, signingTime); err != nil {
+		t.Fatalf("failed to sign request: %v", err)
+	}
+	return req
+}
+
+func TestRequireSignatureInvalidConfig(t *testing.T) {
+	_, err := RequireSignature(Config{}, http.NotFoundHandler())
+	if err == nil {
+		t.Fatal("expected error for invalid config")
+	}
+}
+
+func TestRequireSignatureNilNext(t *testing.T) {
+	_, err := RequireSignature(newTestConfig(), nil)
+	if err == nil {
+		t.Fatal("expected error for nil next handler")
+	}
+}
+
+func TestRequireSignaturePassesSignatureInfo(t *testing.T) {
+	var info *SignatureInfo
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, _ = SignatureInfoFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler, signer := newTestSignatureHandler(t, next)
+
+	signingTime := time.Now().Truncate(time.Second)
+	req := signedRequest(t, signer, signingTime, []byte(`{}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+	if info == nil {
+		t.Fatal("expected SignatureInfo in request context")
+	}
+	if info.GameId != testGameId {
+		t.Errorf("expected GameId %s, got %s", testGameId, info.GameId)
+	}
+	if !info.SigningTime.Equal(signingTime) {
+		t.Errorf("expected SigningTime %s, got %s", signingTime, info.SigningTime)
+	}
+}
+
+func TestRequireSignatureRejections(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    func(signer *httpSigner) *http.Request
+		wantStatus int
+		wantError  GmError
+	}{
+		{
+			name: "method not allowed",
+			request: func(_ *httpSigner) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/callback", nil)
+			},
+			wantStatus: http.StatusMethodNotAllowed,
+			wantError:  GmError_InvalidHttpMethod,
+		},
+		{
+			name: "wrong content type",
+			request: func(_ *httpSigner) *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/callback", bytes.NewBufferString("data"))
+				req.Header.Set("Content-Type", "text/plain")
+				return req
+			},
+			wantStatus: http.StatusUnsupportedMediaType,
+			wantError:  GmError_InvalidContentType,
+		},
+		{
+			name: "missing signature",
+			request: func(_ *httpSigner) *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/callback", bytes.NewBufferString("{}"))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantError:  GmError_InvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			})
+			handler, signer := newTestSignatureHandler(t, next, WithErrorWriter(GmErrorWriter))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, tt.request(signer))
+
+			if called {
+				t.Fatal("next handler should not be called")
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+			var resp GmErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("expected GM style JSON error response: %v", err)
+			}
+			if resp.Error != tt.wantError {
+				t.Fatalf("expected error %s, got %s", tt.wantError, resp.Error)
+			}
+		})
+	}
+}
+
+func TestRequireSignaturePlainTextErrorWriter(t *testing.T) {
+	handler, _ := newTestSignatureHandler(t, http.NotFoundHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/callback", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Fatalf("expected plain text response, got %s", ct)
+	}
+}
+
+func TestRequireSignatureNilErrorWriter(t *testing.T) {
+	handler, _ := newTestSignatureHandler(t, http.NotFoundHandler(), WithErrorWriter(nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/callback", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestSignatureInfoFromContextMissing(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	info, ok := SignatureInfoFromContext(req.Context())
+	if ok || info != nil {
+		t.Fatal("expected no SignatureInfo in context")
+	}
+}
+
+func TestRequireSignatureRejectsLargeBody(t *testing.T) {
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+	handler, signer := newTestSignatureHandler(t, next, WithMaxBodySize(16))
+
+	body := []byte(`{"key":"a value longer than sixteen bytes"}`)
+
+	// Rejected based on the Content-Length header, before reading the body.
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest(t, signer, time.Now(), body))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+
+	// Rejected while reading the body, when the Content-Length is unknown.
+	req := signedRequest(t, signer, time.Now(), body)
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+	if called {
+		t.Fatal("next handler should not be called")
+	}
+}
+
+func TestRequireSignatureBodyWithinLimit(t *testing.T) {
+	var got []byte
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = io.ReadAll(r.Body)
+	})
+	handler, signer := newTestSignatureHandler(t, next, WithMaxBodySize(16))
+
+	body := []byte(`{"key":"value"}`)
+	req := signedRequest(t, signer, time.Now(), body)
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if !bytes.Equal(got, body) {
+		t.Fatalf("expected next handler to read body %s, got %s", body, got)
+	}
+}
+
+func TestRequireSignatureUnlimitedBodyIgnoresDeclaredLength(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler, signer := newTestSignatureHandler(t, next, WithMaxBodySize(0))
+
+	// The declared Content-Length must not be trusted for pre-allocating the body buffer.
+	req := signedRequest(t, signer, time.Now(), []byte(`{"key":"value"}`))
+	req.ContentLength = 1 << 40
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+}
+
+func TestGmHandlerRequestTooLarge(t *testing.T) {
+	handler, err := NewGmHandler(newTestConfig(), &mockGmListener{}, WithMaxBodySize(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &httpSigner{game: testGameId, signingKey: SecretKey(testSecretKey)}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedGmRequest(t, signer, validGmBody(t)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+	var resp GmErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("expected GM style JSON error response: %v", err)
+	}
+	if resp.Error != GmError_RequestTooLarge {
+		t.Fatalf("expected error %s, got %s", GmError_RequestTooLarge, resp.Error)
+	}
+}
@@ -0,0 +1,475 @@
+package combo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrSessionRevoked 表示游戏会话已被吊销或已过期。
+	ErrSessionRevoked = errors.New("session is revoked")
+
+	// ErrRefreshTokenReused 表示 refresh token 已经被使用过。出现这种情况时，整个游戏会话会被吊销。
+	ErrRefreshTokenReused = errors.New("refresh token is reused")
+)
+
+const (
+	sessionAccessTokenScope  = "session_access"
+	sessionRefreshTokenScope = "session_refresh"
+
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// sessionSigningKeyLabel 用于从 SecretKey 派生游戏会话 Token 的签名密钥，以免和世游服务端颁发的 Token 混用同一个密钥。
+const sessionSigningKeyLabel = "combo-sdk-go session token signing key"
+
+// SessionIssuerConfig 包含了创建 SessionIssuer 时所必需的配置项。
+type SessionIssuerConfig struct {
+	// Combo SDK 的配置。Token 的 aud 为 Config.GameId，时间取自 Config.Clock。
+	Config Config
+
+	// 游戏会话的存储，用于 refresh token 的轮换和会话吊销。
+	// 实现可以是 Redis 或 Memory，也可以自行实现 SessionTokenStore。
+	Store SessionTokenStore
+
+	// 游戏会话 Token 的签名密钥，可选。如果不指定，则从 Config.SecretKey 派生。
+	// 注意：如果不指定，轮换 Config.SecretKey 会使所有已颁发的游戏会话 Token 失效。
+	SigningKey []byte
+
+	// access token 的有效期，如果不指定，则默认为 15 分钟。
+	AccessTokenTTL time.Duration
+
+	// refresh token 的有效期，如果不指定，则默认为 30 天。每次刷新都会颁发新的 refresh token 并重新计算有效期。
+	RefreshTokenTTL time.Duration
+
+	// 为 true 时，VerifyAccessToken 不查询 Store，会话被吊销后，已颁发的 access token 在过期之前仍然有效。
+	// 适用于对 Store 的访问延迟敏感的场景。
+	StatelessAccessTokens bool
+}
+
+func (cfg *SessionIssuerConfig) validate() error {
+	if err := cfg.Config.validate(); err != nil {
+		return err
+	}
+	if cfg.Store == nil {
+		return errors.New("missing required Store")
+	}
+	if len(cfg.SigningKey) == 0 {
+		cfg.SigningKey = cfg.Config.SecretKey.hmacSha256([]byte(sessionSigningKeyLabel))
+	}
+	if cfg.AccessTokenTTL <= 0 {
+		cfg.AccessTokenTTL = defaultAccessTokenTTL
+	}
+	if cfg.RefreshTokenTTL <= 0 {
+		cfg.RefreshTokenTTL = defaultRefreshTokenTTL
+	}
+	return nil
+}
+
+// SessionIssuer 用于在 Identity Token 验证通过之后，颁发游戏自己的会话凭证。
+//
+// 游戏会话由一个短期有效的 access token 和一个可轮换的 refresh token 组成，两者都是 HS256 签名的 JWT。
+// 游戏网关和游戏服务可以统一使用 VerifyAccessToken 验证 access token，而不必各自实现会话凭证。
+//
+// refresh token 每次使用后都会被替换。如果一个已经被替换的 refresh token 再次被使用，
+// 说明 refresh token 可能已经泄漏，此时整个游戏会话会被吊销，Refresh 返回 ErrRefreshTokenReused。
+type SessionIssuer struct {
+	parser                *jwt.Parser
+	game                  GameId
+	clock                 Clock
+	store                 SessionTokenStore
+	signingKey            []byte
+	accessTokenTTL        time.Duration
+	refreshTokenTTL       time.Duration
+	statelessAccessTokens bool
+}
+
+// NewSessionIssuer 创建一个 SessionIssuer。
+func NewSessionIssuer(cfg SessionIssuerConfig) (*SessionIssuer, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &SessionIssuer{
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+			jwt.WithExpirationRequired(),
+			jwt.WithAudience(string(cfg.Config.GameId)),
+			jwt.WithTimeFunc(cfg.Config.Clock.Now),
+		),
+		game:                  cfg.Config.GameId,
+		clock:                 cfg.Config.Clock,
+		store:                 cfg.Store,
+		signingKey:            cfg.SigningKey,
+		accessTokenTTL:        cfg.AccessTokenTTL,
+		refreshTokenTTL:       cfg.RefreshTokenTTL,
+		statelessAccessTokens: cfg.StatelessAccessTokens,
+	}, nil
+}
+
+// SessionTokens 是 SessionIssuer 颁发的游戏会话凭证。
+type SessionTokens struct {
+	// 游戏会话的唯一 ID。同一个会话中轮换的 refresh token 共享同一个 SessionId。
+	SessionId string
+
+	AccessToken          string
+	AccessTokenExpiresAt time.Time
+
+	RefreshToken          string
+	RefreshTokenExpiresAt time.Time
+}
+
+// SessionPayload 包含了 access token 中的用户信息。
+//
+// 出于安全考虑，游戏会话 Token 不包含 WeixinSessionKey 等敏感信息。
+type SessionPayload struct {
+	// 游戏会话的唯一 ID。
+	SessionId string
+
+	// 以下字段和 IdentityPayload 中的同名字段含义相同。
+	ComboId  string
+	IdP      IdP
+	DeviceId string
+	Distro   string
+	Variant  string
+	Age      int
+
+	tokenMetadata
+}
+
+type sessionClaims struct {
+	jwt.RegisteredClaims
+	Scope     string `json:"scope"`
+	SessionId string `json:"sid"`
+	IdP       string `json:"idp,omitempty"`
+	DeviceId  string `json:"device_id,omitempty"`
+	Distro    string `json:"distro,omitempty"`
+	Variant   string `json:"variant,omitempty"`
+	Age       int    `json:"age,omitempty"`
+}
+
+// Issue 为 identity 创建一个新的游戏会话，颁发 access token 和 refresh token。
+//
+// 游戏侧应当在 VerifyIdentityToken 验证通过之后调用。
+func (s *SessionIssuer) Issue(ctx context.Context, identity *IdentityPayload) (*SessionTokens, error) {
+	sessionId, err := randomSessionId()
+	if err != nil {
+		return nil, err
+	}
+	claims := &sessionClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: identity.ComboId},
+		SessionId:        sessionId,
+		IdP:              string(identity.IdP),
+		DeviceId:         identity.DeviceId,
+		Distro:           identity.Distro,
+		Variant:          identity.Variant,
+		Age:              identity.Age,
+	}
+	tokens, refreshTokenId, err := s.sign(claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Create(ctx, sessionId, refreshTokenId, s.refreshTokenTTL); err != nil {
+		return nil, &TokenStoreError{Op: "creating session", Err: err}
+	}
+	return tokens, nil
+}
+
+// Refresh 使用 refresh token 颁发新的 access token 和 refresh token，旧的 refresh token 随即失效。
+//
+// 如果会话已被吊销或已过期，返回 ErrSessionRevoked。
+// 如果 refresh token 已经被使用过，吊销整个会话并返回 ErrRefreshTokenReused。
+// 注意：客户端不应当并发刷新同一个会话，否则后到达的请求会被视为重复使用。
+func (s *SessionIssuer) Refresh(ctx context.Context, refreshToken string) (*SessionTokens, error) {
+	claims, err := s.parse(refreshToken, sessionRefreshTokenScope)
+	if err != nil {
+		return nil, err
+	}
+	tokens, newTokenId, err := s.sign(claims)
+	if err != nil {
+		return nil, err
+	}
+	rotated, active, err := s.store.Rotate(ctx, claims.SessionId, claims.ID, newTokenId, s.refreshTokenTTL)
+	if err != nil {
+		return nil, &TokenStoreError{Op: "rotating refresh token", Err: err}
+	}
+	if !active {
+		return nil, ErrSessionRevoked
+	}
+	if !rotated {
+		if err := s.store.Revoke(ctx, claims.SessionId); err != nil {
+			return nil, &TokenStoreError{Op: "revoking session", Err: err}
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	return tokens, nil
+}
+
+// VerifyAccessToken 对 access token 进行验证。
+//
+// 如果验证通过，返回 SessionPayload。如果会话已被吊销，返回 ErrSessionRevoked。
+// 如果配置了 StatelessAccessTokens，则不检查会话是否已被吊销。
+func (s *SessionIssuer) VerifyAccessToken(ctx context.Context, accessToken string) (*SessionPayload, error) {
+	claims, err := s.parse(accessToken, sessionAccessTokenScope)
+	if err != nil {
+		return nil, err
+	}
+	if !s.statelessAccessTokens {
+		active, err := s.store.IsActive(ctx, claims.SessionId)
+		if err != nil {
+			return nil, &TokenStoreError{Op: "checking session", Err: err}
+		}
+		if !active {
+			return nil, ErrSessionRevoked
+		}
+	}
+	return &SessionPayload{
+		SessionId:     claims.SessionId,
+		ComboId:       claims.Subject,
+		IdP:           IdP(claims.IdP),
+		DeviceId:      claims.DeviceId,
+		Distro:        claims.Distro,
+		Variant:       claims.Variant,
+		Age:           claims.Age,
+		tokenMetadata: newTokenMetadata(&claims.RegisteredClaims),
+	}, nil
+}
+
+// Revoke 吊销游戏会话。会话中的 refresh token 立即失效，access token 的失效时机参见 StatelessAccessTokens。
+func (s *SessionIssuer) Revoke(ctx context.Context, sessionId string) error {
+	return s.store.Revoke(ctx, sessionId)
+}
+
+// sign 根据 claims 中的用户信息颁发一对新的 Token，同时返回 refresh token 的 jti。
+func (s *SessionIssuer) sign(claims *sessionClaims) (*SessionTokens, string, error) {
+	now := s.clock.Now()
+	tokens := &SessionTokens{
+		SessionId:             claims.SessionId,
+		AccessTokenExpiresAt:  now.Add(s.accessTokenTTL),
+		RefreshTokenExpiresAt: now.Add(s.refreshTokenTTL),
+	}
+	var err error
+	if tokens.AccessToken, _, err = s.signToken(claims, sessionAccessTokenScope, now, tokens.AccessTokenExpiresAt); err != nil {
+		return nil, "", err
+	}
+	var refreshTokenId string
+	if tokens.RefreshToken, refreshTokenId, err = s.signToken(claims, sessionRefreshTokenScope, now, tokens.RefreshTokenExpiresAt); err != nil {
+		return nil, "", err
+	}
+	return tokens, refreshTokenId, nil
+}
+
+func (s *SessionIssuer) signToken(claims *sessionClaims, scope string, issuedAt, expiresAt time.Time) (string, string, error) {
+	tokenId, err := randomSessionId()
+	if err != nil {
+		return "", "", err
+	}
+	c := *claims
+	c.RegisteredClaims = jwt.RegisteredClaims{
+		Subject:   claims.Subject,
+		Audience:  jwt.ClaimStrings{string(s.game)},
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		IssuedAt:  jwt.NewNumericDate(issuedAt),
+		ID:        tokenId,
+	}
+	c.Scope = scope
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &c).SignedString(s.signingKey)
+	if err != nil {
+		return "", "", fmt.Errorf("error signing %s token: %w", scope, err)
+	}
+	return signed, tokenId, nil
+}
+
+func (s *SessionIssuer) parse(tokenString, scope string) (*sessionClaims, error) {
+	claims := &sessionClaims{}
+	_, err := s.parser.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+		return s.signingKey, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error parsing token: %w", err)
+	}
+	if claims.Scope != scope {
+		return nil, fmt.Errorf("invalid scope: %s", claims.Scope)
+	}
+	if claims.SessionId == "" || claims.ID == "" {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
+
+func randomSessionId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SessionTokenStore 是一个用于存储游戏会话的接口。每个会话记录了当前有效的 refresh token 的 ID (jti)。
+//
+// Combo SDK 内置了 Redis 和 Memory 两种实现，可分别通过 NewMemorySessionTokenStore() 和 NewRedisSessionTokenStore() 创建。
+//
+// 游戏侧也可以选择自行实现 SessionTokenStore 接口。实现必须保证 Rotate 是原子的。
+type SessionTokenStore interface {
+	// Create 创建一个会话，当前的 refresh token 为 tokenId，会话在 ttl 之后过期。
+	Create(ctx context.Context, sessionId, tokenId string, ttl time.Duration) error
+
+	// Rotate 当会话当前的 refresh token 为 oldTokenId 时，将其替换为 newTokenId，并将会话的过期时间重置为 ttl。
+	//
+	// rotated 表示是否替换成功，active 表示会话是否存在（没有被吊销，也没有过期）。
+	Rotate(ctx context.Context, sessionId, oldTokenId, newTokenId string, ttl time.Duration) (rotated, active bool, err error)
+
+	// Revoke 吊销会话。如果会话不存在，则忽略。
+	Revoke(ctx context.Context, sessionId string) error
+
+	// IsActive 判断会话是否存在，即没有被吊销，也没有过期。
+	IsActive(ctx context.Context, sessionId string) (bool, error)
+}
+
+// NewMemorySessionTokenStore 创建一个基于 Memory 的 SessionTokenStore 实现。
+//
+// 注意：该实现仅用于开发调试，不适合生产环境。
+//
+// 数据仅在内存中存储，重启服务后数据会丢失，并且无法在多个游戏服务实例之间共享。
+func NewMemorySessionTokenStore(options ...MemoryStoreOption) SessionTokenStore {
+	return &memorySessionTokenStore{
+		clock:    newMemoryStoreOptions(options).clock,
+		sessions: make(map[string]memorySessionToken),
+	}
+}
+
+// NewRedisSessionTokenStore 创建一个基于 Redis 的 SessionTokenStore 实现。
+//
+// 数据会存储在 Redis 中，可以在多个游戏服务实例之间共享，并且到期自动清理。推荐生产环境使用。
+func NewRedisSessionTokenStore(cfg RedisSessionTokenStoreConfig) SessionTokenStore {
+	if cfg.Client == nil {
+		panic("missing required cfg.Client")
+	}
+	return &redisSessionTokenStore{
+		client: cfg.Client,
+		prefix: cfg.Prefix,
+	}
+}
+
+// RedisSessionTokenStoreConfig 包含了创建基于 Redis 的 SessionTokenStore 时所必需的配置项。
+type RedisSessionTokenStoreConfig struct {
+	Client redis.Cmdable // Redis 客户端。这里不假设 Redis 的运维部署方式。可以是 redis.Client 或者 redis.ClusterClient，由游戏侧自行创建和配置。
+	Prefix string        // Key 的前缀，如果不指定，则默认为空字符串。
+}
+
+type memorySessionToken struct {
+	tokenId   string
+	expiresAt time.Time
+}
+
+type memorySessionTokenStore struct {
+	mu       sync.Mutex
+	clock    Clock
+	sessions map[string]memorySessionToken
+}
+
+// Create implements SessionTokenStore.
+func (s *memorySessionTokenStore) Create(ctx context.Context, sessionId, tokenId string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionId] = memorySessionToken{tokenId: tokenId, expiresAt: s.clock.Now().Add(ttl)}
+	return nil
+}
+
+// Rotate implements SessionTokenStore.
+func (s *memorySessionTokenStore) Rotate(ctx context.Context, sessionId, oldTokenId, newTokenId string, ttl time.Duration) (bool, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.get(sessionId)
+	if !ok {
+		return false, false, nil
+	}
+	if current.tokenId != oldTokenId {
+		return false, true, nil
+	}
+	s.sessions[sessionId] = memorySessionToken{tokenId: newTokenId, expiresAt: s.clock.Now().Add(ttl)}
+	return true, true, nil
+}
+
+// Revoke implements SessionTokenStore.
+func (s *memorySessionTokenStore) Revoke(ctx context.Context, sessionId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionId)
+	return nil
+}
+
+// IsActive implements SessionTokenStore.
+func (s *memorySessionTokenStore) IsActive(ctx context.Context, sessionId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.get(sessionId)
+	return ok, nil
+}
+
+func (s *memorySessionTokenStore) get(sessionId string) (memorySessionToken, bool) {
+	current, ok := s.sessions[sessionId]
+	if !ok || !s.clock.Now().Before(current.expiresAt) {
+		delete(s.sessions, sessionId)
+		return memorySessionToken{}, false
+	}
+	return current, true
+}
+
+// 返回 0 表示会话不存在，1 表示 refresh token 不匹配，2 表示替换成功。
+var sessionTokenRotateScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if not current then
+	return 0
+end
+if current ~= ARGV[1] then
+	return 1
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 2
+`)
+
+type redisSessionTokenStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+func (s *redisSessionTokenStore) key(sessionId string) string {
+	return s.prefix + "session_token:" + sessionId
+}
+
+// Create implements SessionTokenStore.
+func (s *redisSessionTokenStore) Create(ctx context.Context, sessionId, tokenId string, ttl time.Duration) error {
+	return s.client.Set(ctx, s.key(sessionId), tokenId, ttl).Err()
+}
+
+// Rotate implements SessionTokenStore.
+func (s *redisSessionTokenStore) Rotate(ctx context.Context, sessionId, oldTokenId, newTokenId string, ttl time.Duration) (bool, bool, error) {
+	result, err := sessionTokenRotateScript.Run(ctx, s.client, []string{s.key(sessionId)}, oldTokenId, newTokenId, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, false, err
+	}
+	return result == 2, result > 0, nil
+}
+
+// Revoke implements SessionTokenStore.
+func (s *redisSessionTokenStore) Revoke(ctx context.Context, sessionId string) error {
+	return s.client.Del(ctx, s.key(sessionId)).Err()
+}
+
+// IsActive implements SessionTokenStore.
+func (s *redisSessionTokenStore) IsActive(ctx context.Context, sessionId string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.key(sessionId)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
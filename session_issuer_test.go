@@ -0,0 +1,218 @@
+package combo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestSessionIssuer(t *testing.T, cfg SessionIssuerConfig) *SessionIssuer {
+	t.Helper()
+	if cfg.Config.Endpoint == "" {
+		cfg.Config = newTestConfig()
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemorySessionTokenStore()
+	}
+	issuer, err := NewSessionIssuer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return issuer
+}
+
+func testIdentity() *IdentityPayload {
+	return &IdentityPayload{
+		ComboId:          "combo_123",
+		IdP:              IdP_MinigameWeixin,
+		WeixinSessionKey: "secret",
+		DeviceId:         "device_1",
+		Distro:           "official",
+		Age:              16,
+	}
+}
+
+func TestSessionIssuerIssueAndVerify(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	issuer := newTestSessionIssuer(t, SessionIssuerConfig{Config: newFrozenConfig(now)})
+	ctx := context.Background()
+
+	tokens, err := issuer.Issue(ctx, testIdentity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tokens.AccessTokenExpiresAt.Equal(now.Add(15*time.Minute)) || !tokens.RefreshTokenExpiresAt.Equal(now.Add(30*24*time.Hour)) {
+		t.Fatalf("unexpected expiry: %+v", tokens)
+	}
+	payload, err := issuer.VerifyAccessToken(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.SessionId != tokens.SessionId || payload.ComboId != "combo_123" || payload.IdP != IdP_MinigameWeixin ||
+		payload.DeviceId != "device_1" || payload.Distro != "official" || payload.Age != 16 {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+	if !payload.IssuedAt().Equal(now) || payload.TokenId() == "" {
+		t.Fatalf("unexpected token metadata: iat=%v jti=%s", payload.IssuedAt(), payload.TokenId())
+	}
+
+	if _, err := issuer.VerifyAccessToken(ctx, tokens.RefreshToken); err == nil {
+		t.Fatal("expected refresh token to be rejected as access token")
+	}
+	if _, err := issuer.Refresh(ctx, tokens.AccessToken); err == nil {
+		t.Fatal("expected access token to be rejected as refresh token")
+	}
+
+	// Session tokens are not signed with the SecretKey, so they cannot be used as identity tokens.
+	if _, err := newTestVerifierWithOptions(t, now).VerifyIdentityToken(tokens.AccessToken); err == nil {
+		t.Fatal("expected session token to be rejected by TokenVerifier")
+	}
+}
+
+func TestSessionIssuerAccessTokenExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	issuer := newTestSessionIssuer(t, SessionIssuerConfig{
+		Config:         Config{Endpoint: testEndpoint, GameId: testGameId, SecretKey: SecretKey(testSecretKey), Clock: ClockFunc(func() time.Time { return now })},
+		AccessTokenTTL: time.Minute,
+	})
+	ctx := context.Background()
+	tokens, err := issuer.Issue(ctx, testIdentity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := issuer.VerifyAccessToken(ctx, tokens.AccessToken); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Fatalf("expected expired access token, got %v", err)
+	}
+	refreshed, err := issuer.Refresh(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.VerifyAccessToken(ctx, refreshed.AccessToken); err != nil {
+		t.Fatalf("expected refreshed access token to be valid, got %v", err)
+	}
+}
+
+func TestSessionIssuerRefreshRotation(t *testing.T) {
+	issuer := newTestSessionIssuer(t, SessionIssuerConfig{})
+	ctx := context.Background()
+
+	first, err := issuer.Issue(ctx, testIdentity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := issuer.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.SessionId != first.SessionId || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("expected rotated refresh token in the same session, got %+v", second)
+	}
+	payload, err := issuer.VerifyAccessToken(ctx, second.AccessToken)
+	if err != nil || payload.ComboId != "combo_123" || payload.DeviceId != "device_1" {
+		t.Fatalf("expected refreshed access token to keep the identity, got %+v, err=%v", payload, err)
+	}
+
+	// Reusing the old refresh token revokes the whole session.
+	if _, err := issuer.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := issuer.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("expected ErrSessionRevoked, got %v", err)
+	}
+	if _, err := issuer.VerifyAccessToken(ctx, second.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("expected ErrSessionRevoked, got %v", err)
+	}
+}
+
+func TestSessionIssuerRevoke(t *testing.T) {
+	store := NewMemorySessionTokenStore()
+	issuer := newTestSessionIssuer(t, SessionIssuerConfig{Store: store})
+	stateless := newTestSessionIssuer(t, SessionIssuerConfig{Store: store, StatelessAccessTokens: true})
+	ctx := context.Background()
+
+	tokens, err := issuer.Issue(ctx, testIdentity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := issuer.Revoke(ctx, tokens.SessionId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.VerifyAccessToken(ctx, tokens.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("expected ErrSessionRevoked, got %v", err)
+	}
+	if _, err := stateless.VerifyAccessToken(ctx, tokens.AccessToken); err != nil {
+		t.Fatalf("expected stateless access token to stay valid until expiry, got %v", err)
+	}
+	if _, err := issuer.Refresh(ctx, tokens.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("expected ErrSessionRevoked, got %v", err)
+	}
+}
+
+func TestSessionIssuerSigningKey(t *testing.T) {
+	store := NewMemorySessionTokenStore()
+	issuer := newTestSessionIssuer(t, SessionIssuerConfig{Store: store, SigningKey: []byte("separate_key")})
+	other := newTestSessionIssuer(t, SessionIssuerConfig{Store: store})
+	ctx := context.Background()
+
+	tokens, err := issuer.Issue(ctx, testIdentity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.VerifyAccessToken(ctx, tokens.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.VerifyAccessToken(ctx, tokens.AccessToken); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Fatalf("expected signature error with derived key, got %v", err)
+	}
+}
+
+func TestNewSessionIssuerValidation(t *testing.T) {
+	if _, err := NewSessionIssuer(SessionIssuerConfig{Config: newTestConfig()}); err == nil {
+		t.Fatal("expected error for missing Store")
+	}
+	if _, err := NewSessionIssuer(SessionIssuerConfig{Store: NewMemorySessionTokenStore()}); err == nil {
+		t.Fatal("expected error for invalid Config")
+	}
+}
+
+func TestRedisSessionTokenStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := NewRedisSessionTokenStore(RedisSessionTokenStoreConfig{Client: client, Prefix: "game:"})
+	ctx := context.Background()
+
+	if err := store.Create(ctx, "sid", "t1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := mr.TTL("game:session_token:sid"); ttl != time.Hour {
+		t.Fatalf("expected ttl to be 1h, got %v", ttl)
+	}
+	mr.FastForward(30 * time.Minute)
+	if rotated, active, err := store.Rotate(ctx, "sid", "t1", "t2", time.Hour); err != nil || !rotated || !active {
+		t.Fatalf("expected rotation to succeed, got rotated=%v active=%v err=%v", rotated, active, err)
+	}
+	if ttl := mr.TTL("game:session_token:sid"); ttl != time.Hour {
+		t.Fatalf("expected ttl to be reset on rotation, got %v", ttl)
+	}
+	if rotated, active, _ := store.Rotate(ctx, "sid", "t1", "t3", time.Hour); rotated || !active {
+		t.Fatalf("expected stale rotation to fail, got rotated=%v active=%v", rotated, active)
+	}
+	if ok, _ := store.IsActive(ctx, "sid"); !ok {
+		t.Fatal("expected session to be active")
+	}
+	if err := store.Revoke(ctx, "sid"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := store.IsActive(ctx, "sid"); ok {
+		t.Fatal("expected session to be revoked")
+	}
+	if rotated, active, _ := store.Rotate(ctx, "sid", "t2", "t3", time.Hour); rotated || active {
+		t.Fatalf("expected rotation of revoked session to fail, got rotated=%v active=%v", rotated, active)
+	}
+}
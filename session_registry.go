@@ -0,0 +1,320 @@
+package combo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrSessionDisplaced 表示用户已经在其他设备上登录，当前设备的会话已被顶替。
+	ErrSessionDisplaced = errors.New("session is displaced by another device")
+
+	// ErrMissingLoginTime 表示 Identity Token 不包含签发时间 iat，无法判定会话的先后顺序。
+	ErrMissingLoginTime = errors.New("identity token has no issued at time")
+)
+
+// 会话记录的默认过期时间。
+const defaultSessionTTL = 30 * 24 * time.Hour
+
+// Session 是用户在某个设备上的登录会话。
+type Session struct {
+	// 世游分配的聚合用户 ID。
+	ComboId string
+
+	// 登录时使用的设备 ID。
+	DeviceId string
+
+	// 登录时间，即 Identity Token 的签发时间 iat。
+	LoginTime time.Time
+}
+
+// SessionDisplacedFunc 在用户的旧会话被新设备上的登录顶替时调用。
+//
+// displaced 是被顶替的旧会话，current 是新的会话。游戏侧通常在这里通知旧设备所在的网关断开连接。
+type SessionDisplacedFunc func(ctx context.Context, displaced, current Session)
+
+// SessionRegistryConfig 包含了创建 SessionRegistry 时所必需的配置项。
+type SessionRegistryConfig struct {
+	Store       SessionStore         // 会话记录的存储。实现可以是 Redis 或 Memory，也可以自行实现 SessionStore
+	TTL         time.Duration        // 会话记录的过期时间，应当长于用户的最长在线时间。如果不指定，则默认为 30 天
+	OnDisplaced SessionDisplacedFunc // 旧会话被顶替时的回调，可选
+}
+
+// SessionRegistry 用于实现单设备登录：每个 ComboId 同一时间只有一个设备上的会话是有效的。
+//
+// 每次登录（Identity Token 验证通过）时，SessionRegistry 以 ComboId 为 key 记录设备 ID 和登录时间。
+// 只有登录时间更晚的会话才能顶替其他设备上的会话，所以旧设备重新验证旧的 Identity Token 不会把新设备顶掉。
+//
+// 通常通过 WithSessionRegistry 在 TokenVerifier 中使用，也可以由游戏侧在登录流程中直接调用 Register。
+// DeviceId 为空的 Identity Token 不受 SessionRegistry 的管理。
+// DeviceId 不为空但不包含签发时间 iat 的 Identity Token 无法判定先后顺序，会被拒绝。
+type SessionRegistry struct {
+	store       SessionStore
+	ttl         time.Duration
+	onDisplaced SessionDisplacedFunc
+}
+
+// NewSessionRegistry 创建一个 SessionRegistry。
+func NewSessionRegistry(cfg SessionRegistryConfig) *SessionRegistry {
+	if cfg.Store == nil {
+		panic("missing required cfg.Store")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultSessionTTL
+	}
+	return &SessionRegistry{
+		store:       cfg.Store,
+		ttl:         cfg.TTL,
+		onDisplaced: cfg.OnDisplaced,
+	}
+}
+
+// WithSessionRegistry 用于在 Identity Token 验证通过时，将登录会话注册到 registry 中。
+//
+// 如果 Token 对应的会话已经被其他设备上更晚的登录顶替，则验证失败，返回 ErrSessionDisplaced。
+func WithSessionRegistry(registry *SessionRegistry) VerifierOption {
+	return func(v *TokenVerifier) {
+		v.sessionRegistry = registry
+	}
+}
+
+// Register 将 identity 对应的会话注册为用户的当前会话。
+//
+// 如果顶替了其他设备上的旧会话，则调用 SessionRegistryConfig.OnDisplaced。
+// 如果 identity 对应的会话已经被其他设备上更晚的登录顶替，则返回 ErrSessionDisplaced。
+// 如果 identity 不包含签发时间 iat，则返回 ErrMissingLoginTime。
+func (r *SessionRegistry) Register(ctx context.Context, identity *IdentityPayload) error {
+	if identity.DeviceId == "" {
+		return nil
+	}
+	session, ok := sessionOf(identity)
+	if !ok {
+		return ErrMissingLoginTime
+	}
+	ok, other, err := r.store.Claim(ctx, session, r.ttl)
+	if err != nil {
+		return &TokenStoreError{Op: "registering session", Err: err}
+	}
+	if !ok {
+		return ErrSessionDisplaced
+	}
+	if other != nil && other.DeviceId != session.DeviceId && r.onDisplaced != nil {
+		r.onDisplaced(ctx, *other, session)
+	}
+	return nil
+}
+
+// IsCurrent 判断 identity 对应的会话是否仍然有效，即没有被其他设备上更晚的登录顶替。
+//
+// 游戏网关可以在长连接上定期调用 IsCurrent，以便在没有收到 OnDisplaced 回调的节点上也能踢掉旧会话。
+// 不包含签发时间 iat 的 identity 只有在没有其他设备上的会话时才被视为有效。
+func (r *SessionRegistry) IsCurrent(ctx context.Context, identity *IdentityPayload) (bool, error) {
+	if identity.DeviceId == "" {
+		return true, nil
+	}
+	current, err := r.store.Get(ctx, identity.ComboId)
+	if err != nil {
+		return false, err
+	}
+	if current == nil || current.DeviceId == identity.DeviceId {
+		return true, nil
+	}
+	session, ok := sessionOf(identity)
+	return ok && session.LoginTime.After(current.LoginTime), nil
+}
+
+// Current 返回用户的当前会话。如果用户没有会话记录，则返回 nil。
+func (r *SessionRegistry) Current(ctx context.Context, comboId string) (*Session, error) {
+	return r.store.Get(ctx, comboId)
+}
+
+// sessionOf 返回 identity 对应的会话。Identity Token 不包含 iat 时返回 false。
+//
+// 不能以当前时间代替登录时间，否则旧设备上没有 iat 的 Token 每次验证都会被视为最新的登录，从而顶替新设备。
+func sessionOf(identity *IdentityPayload) (Session, bool) {
+	loginTime := identity.IssuedAt()
+	if loginTime.IsZero() {
+		return Session{}, false
+	}
+	return Session{
+		ComboId:   identity.ComboId,
+		DeviceId:  identity.DeviceId,
+		LoginTime: loginTime,
+	}, true
+}
+
+// checkSession 将通过验证的 Identity Token 注册到 sessionRegistry 中。
+func (v *TokenVerifier) checkSession(ctx context.Context, payload *IdentityPayload) error {
+	if v.sessionRegistry == nil {
+		return nil
+	}
+	return v.sessionRegistry.Register(ctx, payload)
+}
+
+// SessionStore 是一个用于存储用户当前会话的接口。
+//
+// Combo SDK 内置了 Redis 和 Memory 两种实现，可分别通过 NewMemorySessionStore() 和 NewRedisSessionStore() 创建。
+//
+// 游戏侧也可以选择自行实现 SessionStore 接口。实现必须保证 Claim 是原子的。
+type SessionStore interface {
+	// Claim 尝试将 session 设置为 session.ComboId 的当前会话，会话记录在 ttl 之后过期。
+	//
+	// 当用户没有当前会话、当前会话的 DeviceId 与 session 相同、或者 session 的 LoginTime 晚于当前会话时，设置成功，
+	// 返回 true 和设置之前的会话（可能为 nil）。同一个设备重复设置时，LoginTime 保留较晚的一个。
+	// 否则设置失败，返回 false 和当前会话。
+	Claim(ctx context.Context, session Session, ttl time.Duration) (bool, *Session, error)
+
+	// Get 返回用户的当前会话。如果用户没有会话记录，则返回 nil。
+	Get(ctx context.Context, comboId string) (*Session, error)
+}
+
+// NewMemorySessionStore 创建一个基于 Memory 的 SessionStore 实现。
+//
+// 注意：该实现仅用于开发调试，不适合生产环境。
+//
+// 数据仅在内存中存储，重启服务后数据会丢失，并且无法在多个游戏服务实例之间共享。
+func NewMemorySessionStore(options ...MemoryStoreOption) SessionStore {
+	return &memorySessionStore{
+		clock:    newMemoryStoreOptions(options).clock,
+		sessions: make(map[string]memorySession),
+	}
+}
+
+// NewRedisSessionStore 创建一个基于 Redis 的 SessionStore 实现。
+//
+// 数据会存储在 Redis 中，可以在多个游戏服务实例（例如多个网关节点）之间共享，并且到期自动清理。推荐生产环境使用。
+func NewRedisSessionStore(cfg RedisSessionStoreConfig) SessionStore {
+	if cfg.Client == nil {
+		panic("missing required cfg.Client")
+	}
+	return &redisSessionStore{
+		client: cfg.Client,
+		prefix: cfg.Prefix,
+	}
+}
+
+// RedisSessionStoreConfig 包含了创建基于 Redis 的 SessionStore 时所必需的配置项。
+type RedisSessionStoreConfig struct {
+	Client redis.Cmdable // Redis 客户端。这里不假设 Redis 的运维部署方式。可以是 redis.Client 或者 redis.ClusterClient，由游戏侧自行创建和配置。
+	Prefix string        // Key 的前缀，如果不指定，则默认为空字符串。
+}
+
+type memorySession struct {
+	Session
+	expiresAt time.Time
+}
+
+type memorySessionStore struct {
+	mu       sync.Mutex
+	clock    Clock
+	sessions map[string]memorySession
+}
+
+// Claim implements SessionStore.
+func (s *memorySessionStore) Claim(ctx context.Context, session Session, ttl time.Duration) (bool, *Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	var previous *Session
+	if current, ok := s.sessions[session.ComboId]; ok && now.Before(current.expiresAt) {
+		previous = &current.Session
+		if current.DeviceId != session.DeviceId && !session.LoginTime.After(current.LoginTime) {
+			return false, previous, nil
+		}
+		if current.DeviceId == session.DeviceId && session.LoginTime.Before(current.LoginTime) {
+			session.LoginTime = current.LoginTime
+		}
+	}
+	s.sessions[session.ComboId] = memorySession{Session: session, expiresAt: now.Add(ttl)}
+	return true, previous, nil
+}
+
+// Get implements SessionStore.
+func (s *memorySessionStore) Get(ctx context.Context, comboId string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.sessions[comboId]
+	if !ok || !s.clock.Now().Before(current.expiresAt) {
+		return nil, nil
+	}
+	return &current.Session, nil
+}
+
+// 会话记录是一个 Hash，包含 device_id 和 login_time（Unix timestamp in milliseconds）两个字段。
+// 返回值的第一个元素表示是否设置成功，其余元素是设置之前的会话（如果有的话）。
+var sessionClaimScript = redis.NewScript(`
+local current = redis.call("HMGET", KEYS[1], "device_id", "login_time")
+if current[1] and current[1] ~= ARGV[1] and tonumber(ARGV[2]) <= tonumber(current[2]) then
+	return {0, current[1], current[2]}
+end
+local loginTime = ARGV[2]
+if current[1] == ARGV[1] and tonumber(ARGV[2]) < tonumber(current[2]) then
+	loginTime = current[2]
+end
+redis.call("HSET", KEYS[1], "device_id", ARGV[1], "login_time", loginTime)
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+if current[1] then
+	return {1, current[1], current[2]}
+end
+return {1}
+`)
+
+type redisSessionStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+func (s *redisSessionStore) key(comboId string) string {
+	return s.prefix + "session:" + comboId
+}
+
+// Claim implements SessionStore.
+func (s *redisSessionStore) Claim(ctx context.Context, session Session, ttl time.Duration) (bool, *Session, error) {
+	result, err := sessionClaimScript.Run(ctx, s.client, []string{s.key(session.ComboId)},
+		session.DeviceId, session.LoginTime.UnixMilli(), ttl.Milliseconds()).Slice()
+	if err != nil {
+		return false, nil, err
+	}
+	ok, _ := result[0].(int64)
+	var previous *Session
+	if len(result) == 3 {
+		deviceId, _ := result[1].(string)
+		loginTime, _ := result[2].(string)
+		if previous, err = parseRedisSession(session.ComboId, deviceId, loginTime); err != nil {
+			return false, nil, err
+		}
+	}
+	return ok == 1, previous, nil
+}
+
+// Get implements SessionStore.
+func (s *redisSessionStore) Get(ctx context.Context, comboId string) (*Session, error) {
+	values, err := s.client.HMGet(ctx, s.key(comboId), "device_id", "login_time").Result()
+	if err != nil {
+		return nil, err
+	}
+	deviceId, _ := values[0].(string)
+	loginTime, _ := values[1].(string)
+	if deviceId == "" {
+		return nil, nil
+	}
+	return parseRedisSession(comboId, deviceId, loginTime)
+}
+
+func parseRedisSession(comboId, deviceId, loginTime string) (*Session, error) {
+	ms, err := strconv.ParseInt(loginTime, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session login_time %q: %w", loginTime, err)
+	}
+	return &Session{
+		ComboId:   comboId,
+		DeviceId:  deviceId,
+		LoginTime: time.UnixMilli(ms),
+	}, nil
+}
@@ -0,0 +1,165 @@
+package combo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestSession(deviceId string, loginTime time.Time) Session {
+	return Session{ComboId: "combo_123", DeviceId: deviceId, LoginTime: loginTime}
+}
+
+func testSessionStore(t *testing.T, store SessionStore) {
+	t.Helper()
+	ctx := context.Background()
+	login := time.UnixMilli(1700000000000)
+
+	if s, err := store.Get(ctx, "combo_123"); err != nil || s != nil {
+		t.Fatalf("expected no session, got %+v, err=%v", s, err)
+	}
+	ok, previous, err := store.Claim(ctx, newTestSession("device_a", login), time.Hour)
+	if err != nil || !ok || previous != nil {
+		t.Fatalf("expected first claim to succeed, got ok=%v previous=%+v err=%v", ok, previous, err)
+	}
+
+	// An older login on another device cannot displace the current session.
+	ok, previous, err = store.Claim(ctx, newTestSession("device_b", login), time.Hour)
+	if err != nil || ok || previous == nil || previous.DeviceId != "device_a" {
+		t.Fatalf("expected stale claim to fail, got ok=%v previous=%+v err=%v", ok, previous, err)
+	}
+
+	// Re-verifying an older token on the same device keeps the later login time.
+	if ok, _, _ := store.Claim(ctx, newTestSession("device_a", login.Add(-time.Minute)), time.Hour); !ok {
+		t.Fatal("expected claim on the same device to succeed")
+	}
+	if s, _ := store.Get(ctx, "combo_123"); s == nil || !s.LoginTime.Equal(login) {
+		t.Fatalf("expected login time to be kept, got %+v", s)
+	}
+
+	ok, previous, err = store.Claim(ctx, newTestSession("device_b", login.Add(time.Second)), time.Hour)
+	if err != nil || !ok || previous == nil || previous.DeviceId != "device_a" || !previous.LoginTime.Equal(login) {
+		t.Fatalf("expected newer claim to displace device_a, got ok=%v previous=%+v err=%v", ok, previous, err)
+	}
+	s, err := store.Get(ctx, "combo_123")
+	if err != nil || s == nil || s.DeviceId != "device_b" || s.ComboId != "combo_123" {
+		t.Fatalf("expected device_b to be current, got %+v, err=%v", s, err)
+	}
+}
+
+func TestMemorySessionStore(t *testing.T) {
+	testSessionStore(t, NewMemorySessionStore())
+}
+
+func TestRedisSessionStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	testSessionStore(t, NewRedisSessionStore(RedisSessionStoreConfig{Client: client, Prefix: "game:"}))
+	if ttl := mr.TTL("game:session:combo_123"); ttl != time.Hour {
+		t.Fatalf("expected session ttl to be 1h, got %v", ttl)
+	}
+}
+
+func TestVerifierWithSessionRegistry(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	var displaced []Session
+	registry := NewSessionRegistry(SessionRegistryConfig{
+		Store: NewMemorySessionStore(),
+		OnDisplaced: func(ctx context.Context, old, current Session) {
+			if current.DeviceId != "device_b" {
+				t.Errorf("expected device_b to be current, got %+v", current)
+			}
+			displaced = append(displaced, old)
+		},
+	})
+	v := newTestVerifierWithOptions(t, now, WithSessionRegistry(registry))
+	ctx := context.Background()
+
+	claimsA := newTestIdentityClaims(now.Add(-time.Minute))
+	claimsA.DeviceId = "device_a"
+	tokenA := signToken(t, claimsA)
+	claimsB := newTestIdentityClaims(now)
+	claimsB.DeviceId = "device_b"
+	tokenB := signToken(t, claimsB)
+
+	identityA, err := v.VerifyIdentityToken(tokenA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.VerifyIdentityToken(tokenA); err != nil {
+		t.Fatalf("expected re-verification on the same device to succeed, got %v", err)
+	}
+	if _, err := v.VerifyIdentityToken(tokenB); err != nil {
+		t.Fatal(err)
+	}
+	if len(displaced) != 1 || displaced[0].DeviceId != "device_a" {
+		t.Fatalf("expected device_a to be displaced once, got %+v", displaced)
+	}
+
+	_, err = v.VerifyIdentityToken(tokenA)
+	if !errors.Is(err, ErrSessionDisplaced) {
+		t.Fatalf("expected ErrSessionDisplaced, got %v", err)
+	}
+	if describeTokenError(err) != "the session has been displaced by another device" {
+		t.Fatalf("unexpected error description: %s", describeTokenError(err))
+	}
+	if current, _ := registry.IsCurrent(ctx, identityA); current {
+		t.Fatal("expected device_a not to be current")
+	}
+	if s, _ := registry.Current(ctx, "combo_123"); s == nil || s.DeviceId != "device_b" {
+		t.Fatalf("expected device_b to be current, got %+v", s)
+	}
+
+	// Tokens without a DeviceId are not tracked.
+	if _, err := v.VerifyIdentityToken(signToken(t, newTestIdentityClaims(now.Add(-2*time.Minute)))); err != nil {
+		t.Fatalf("expected token without DeviceId to be accepted, got %v", err)
+	}
+	if current, _ := registry.IsCurrent(ctx, &IdentityPayload{ComboId: "combo_123"}); !current {
+		t.Fatal("expected identity without DeviceId to be current")
+	}
+}
+
+func TestVerifierWithSessionRegistryMissingIssuedAt(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	registry := NewSessionRegistry(SessionRegistryConfig{Store: NewMemorySessionStore()})
+	v := newTestVerifierWithOptions(t, now, WithSessionRegistry(registry))
+	ctx := context.Background()
+
+	claimsA := newTestIdentityClaims(now.Add(-time.Minute))
+	claimsA.DeviceId = "device_a"
+	if _, err := v.VerifyIdentityToken(signToken(t, claimsA)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A token without iat must not displace the current session on another device.
+	claimsB := newTestIdentityClaims(now)
+	claimsB.DeviceId = "device_b"
+	claimsB.IssuedAt = nil
+	tokenB := signToken(t, claimsB)
+	identityB, err := newTestVerifierWithOptions(t, now).VerifyIdentityToken(tokenB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.VerifyIdentityToken(tokenB); !errors.Is(err, ErrMissingLoginTime) {
+		t.Fatalf("expected ErrMissingLoginTime, got %v", err)
+	}
+	if s, _ := registry.Current(ctx, "combo_123"); s == nil || s.DeviceId != "device_a" {
+		t.Fatalf("expected device_a to stay current, got %+v", s)
+	}
+	if current, _ := registry.IsCurrent(ctx, identityB); current {
+		t.Fatal("expected identity without iat not to be current")
+	}
+}
+
+func TestNewSessionRegistryMissingStore(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for missing Store")
+		}
+	}()
+	NewSessionRegistry(SessionRegistryConfig{})
+}
@@ -2,12 +2,17 @@ package combo
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -22,27 +27,89 @@ const (
 	// emptyStringSha256 is the hex encoded sha256 value of an empty string
 	emptyStringSha256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
 
-	// maxTimeDiff is the maximum allowed time difference between the signing time and the current time
-	maxTimeDiff = time.Minute * 5
+	// defaultMaxTimeDiff is the default maximum allowed time difference between the signing time and the current time
+	defaultMaxTimeDiff = time.Minute * 5
+
+	// signatureV1 signs the method, request URI, timestamp and payload hash
+	signatureV1 = 1
+
+	// signatureV2 additionally signs the declared headers, and uses the canonical path and query string
+	signatureV2 = 2
 )
 
 // HttpSigner is the interface for signing and verifying http requests
 type httpSigner struct {
 	game       GameId
 	signingKey SecretKey
+	// keyId identifies the signingKey, it is included in the Authorization header if not empty
+	keyId string
+	// keyring contains all the keys accepted when verifying signatures, signingKey is used if empty
+	keyring keyring
+	// clock is used to get the current time, SystemClock is used if nil
+	clock Clock
+	// clockSkew is added to the current time when signing requests, to compensate for a local clock that drifts from the server
+	clockSkew time.Duration
+	// maxTimeDiff is the maximum allowed time difference when verifying signatures, defaultMaxTimeDiff is used if zero
+	maxTimeDiff time.Duration
+	// signedHeaders are the canonical names of headers to sign, signature v2 is used when signing if not empty
+	signedHeaders []string
+	// minVersion is the minimum signature version accepted when verifying signatures
+	minVersion int
+	// debug includes the server side string to sign in the AuthError when the signature is invalid
+	debug bool
+}
+
+func newHttpSigner(cfg Config) httpSigner {
+	return httpSigner{
+		game:       cfg.GameId,
+		signingKey: cfg.SecretKey,
+		keyId:      cfg.SecretKeyId,
+		keyring:    newKeyring(cfg),
+		clock:      cfg.Clock,
+	}
+}
+
+// now returns the current time of the signer's clock
+func (s *httpSigner) now() time.Time {
+	if s.clock == nil {
+		return SystemClock.Now()
+	}
+	return s.clock.Now()
+}
+
+// signingTime returns the current time of the signer's clock, adjusted by clockSkew
+func (s *httpSigner) signingTime() time.Time {
+	return s.now().Add(s.clockSkew)
+}
+
+// maxSkew returns the maximum allowed time difference between the signing time and the current time
+func (s *httpSigner) maxSkew() time.Duration {
+	if s.maxTimeDiff <= 0 {
+		return defaultMaxTimeDiff
+	}
+	return s.maxTimeDiff
 }
 
 type authorization struct {
-	scheme    string
-	game      GameId
-	timestamp time.Time
-	signature string
+	scheme        string
+	game          GameId
+	keyId         string
+	version       int
+	signedHeaders []string
+	timestamp     time.Time
+	signature     string
 }
 
 // SignHttp computes the signature of given http request and set the Authorization header
 func (s *httpSigner) SignHttp(r *http.Request, signingTime time.Time) error {
 	timestamp := getTimestamp(signingTime)
-	stringToSign, err := buildStringToSign(r, timestamp)
+	var stringToSign string
+	var err error
+	if len(s.signedHeaders) > 0 {
+		stringToSign, err = buildStringToSignV2(r, timestamp, s.signedHeaders)
+	} else {
+		stringToSign, err = buildStringToSign(r, timestamp)
+	}
 	if err != nil {
 		return err
 	}
@@ -53,51 +120,99 @@ func (s *httpSigner) SignHttp(r *http.Request, signingTime time.Time) error {
 
 // AuthHttp reads the Authorization header from given http request and verifies the signature
 func (s *httpSigner) AuthHttp(r *http.Request, currentTime time.Time) error {
+	_, err := s.authenticate(r, currentTime)
+	return err
+}
+
+// authenticate is the same as AuthHttp, but returns the verified authorization on success.
+func (s *httpSigner) authenticate(r *http.Request, currentTime time.Time) (*authorization, error) {
 	// Step 1, parse authorization header
 	auth, err := parseAuthorizationHeader(r.Header.Get(authorizationHeader))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// Step 2, verify scheme
 	if auth.scheme != signingAlgorithm {
-		return fmt.Errorf("invalid auth scheme: %s", auth.scheme)
+		return nil, newAuthError(AuthFailure_BadScheme, "invalid auth scheme: %s", auth.scheme)
 	}
 	// Step 3, verify timestamp
 	timeDiff := currentTime.Sub(auth.timestamp).Abs()
-	if timeDiff > maxTimeDiff {
-		return fmt.Errorf("time difference exceeds maximum allowed: %s", timeDiff)
+	if timeDiff > s.maxSkew() {
+		return nil, newAuthError(AuthFailure_ClockSkew, "time difference exceeds maximum allowed: %s", timeDiff)
 	}
 	// Step 4, verify game
 	if auth.game != s.game {
-		return fmt.Errorf("invalid game: expect %s, got %s", s.game, auth.game)
+		return nil, newAuthError(AuthFailure_WrongGame, "invalid game: expect %s, got %s", s.game, auth.game)
+	}
+	// Step 5, verify signature version
+	if auth.version < s.minVersion {
+		return nil, newAuthError(AuthFailure_BadScheme, "signature version %d is not accepted, minimum version is %d", auth.version, s.minVersion)
 	}
-	// Step 5, verify signature
+	if auth.version == signatureV2 && !slices.Contains(auth.signedHeaders, "host") {
+		return nil, newAuthError(AuthFailure_BadScheme, "signed headers must include host")
+	}
+	// Step 6, verify signature
 	timestamp := getTimestamp(auth.timestamp)
-	stringToSign, err := buildStringToSign(r, timestamp)
+	var stringToSign string
+	switch auth.version {
+	case signatureV1:
+		stringToSign, err = buildStringToSign(r, timestamp)
+	case signatureV2:
+		stringToSign, err = buildStringToSignV2(r, timestamp, auth.signedHeaders)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	signature := s.computeSignature(stringToSign)
-	if auth.signature != signature {
-		return fmt.Errorf("invalid signature: %s", auth.signature)
+	keys := s.verifyingKeys(auth.keyId)
+	if len(keys) == 0 {
+		return nil, newAuthError(AuthFailure_BadSignature, "unknown key id: %s", auth.keyId)
 	}
-	return nil
+	for _, entry := range keys {
+		signature := computeSignature(entry.key, stringToSign)
+		if hmac.Equal([]byte(auth.signature), []byte(signature)) {
+			// Report the matched key, so that we know when the previous keys can be retired
+			auth.keyId = entry.id
+			s.keyring.matched(KeyUsage_Signature, entry)
+			return auth, nil
+		}
+	}
+	authErr := newAuthError(AuthFailure_BadSignature, "invalid signature: %s", auth.signature)
+	if s.debug {
+		authErr.StringToSign = stringToSign
+	}
+	return nil, authErr
+}
+
+// verifyingKeys returns the keys that should be tried when verifying a signature
+func (s *httpSigner) verifyingKeys(keyId string) []keyEntry {
+	if len(s.keyring.keys) == 0 {
+		return []keyEntry{{id: s.keyId, key: s.signingKey, primary: true}}
+	}
+	return s.keyring.candidates(keyId)
 }
 
 func (s *httpSigner) computeSignature(stringToSign string) string {
-	sig := s.signingKey.hmacSha256([]byte(stringToSign))
+	return computeSignature(s.signingKey, stringToSign)
+}
+
+func computeSignature(key SecretKey, stringToSign string) string {
+	sig := key.hmacSha256([]byte(stringToSign))
 	return hex.EncodeToString(sig)
 }
 
 func (s *httpSigner) buildAuthorizationHeader(timestamp, signature string) string {
 	// TODO: include space between parameters
 	// return fmt.Sprintf("%s Game=%s, Timestamp=%s, Signature=%s",
-	return fmt.Sprintf("%s Game=%s,Timestamp=%s,Signature=%s",
-		signingAlgorithm,
-		s.game,
-		timestamp,
-		signature,
-	)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s Game=%s", signingAlgorithm, s.game)
+	if s.keyId != "" {
+		fmt.Fprintf(&b, ",KeyId=%s", s.keyId)
+	}
+	if len(s.signedHeaders) > 0 {
+		fmt.Fprintf(&b, ",Version=%d,SignedHeaders=%s", signatureV2, strings.Join(s.signedHeaders, ";"))
+	}
+	fmt.Fprintf(&b, ",Timestamp=%s,Signature=%s", timestamp, signature)
+	return b.String()
 }
 
 func getTimestamp(t time.Time) string {
@@ -118,49 +233,167 @@ func buildStringToSign(r *http.Request, timestamp string) (string, error) {
 	}, "\n"), nil
 }
 
+// buildStringToSignV2 builds the string to sign of signature v2.
+// Comparing to v1, it uses the canonical path and query string, and covers the declared headers.
+func buildStringToSignV2(r *http.Request, timestamp string, signedHeaders []string) (string, error) {
+	if !slices.Contains(signedHeaders, "host") {
+		return "", errors.New("signed headers must include host")
+	}
+	query, err := canonicalQueryString(r.URL.RawQuery)
+	if err != nil {
+		return "", err
+	}
+	payloadHash, err := computePayloadHash(r)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		signingAlgorithm,
+		r.Method,
+		canonicalPath(r.URL),
+		query,
+		timestamp,
+		canonicalHeaders(r, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n"), nil
+}
+
+func canonicalPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// canonicalQueryString sorts the query parameters by name and value, and encodes them per RFC 3986
+func canonicalQueryString(rawQuery string) (string, error) {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		authErr := newAuthError(AuthFailure_BadScheme, "invalid query string: %v", err)
+		authErr.err = err
+		return "", authErr
+	}
+	var pairs []string
+	for name, vs := range values {
+		for _, v := range vs {
+			pairs = append(pairs, uriEncode(name)+"="+uriEncode(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&"), nil
+}
+
+func uriEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// canonicalHeaders returns one "name:value" line for each signed header.
+// Multiple values of the same header are joined by comma, and surrounding spaces are trimmed.
+func canonicalHeaders(r *http.Request, signedHeaders []string) string {
+	lines := make([]string, 0, len(signedHeaders))
+	for _, name := range signedHeaders {
+		var value string
+		if name == "host" {
+			// Go moves the Host header into http.Request.Host
+			value = r.Host
+			if value == "" {
+				value = r.URL.Host
+			}
+		} else {
+			values := r.Header.Values(name)
+			trimmed := make([]string, len(values))
+			for i, v := range values {
+				trimmed[i] = strings.TrimSpace(v)
+			}
+			value = strings.Join(trimmed, ",")
+		}
+		lines = append(lines, name+":"+strings.TrimSpace(value))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// normalizeSignedHeaders lowercases, sorts and deduplicates the header names, host is always included
+func normalizeSignedHeaders(headers []string) []string {
+	normalized := []string{"host"}
+	for _, h := range headers {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(h)))
+	}
+	sort.Strings(normalized)
+	return slices.Compact(normalized)
+}
+
+// maxPayloadPrealloc caps the buffer pre-allocated from the Content-Length header in computePayloadHash.
+const maxPayloadPrealloc = defaultMaxBodySize
+
 func computePayloadHash(r *http.Request) (string, error) {
-	if r.Body == nil {
+	if r.Body == nil || r.Body == http.NoBody {
 		return emptyStringSha256, nil
 	}
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
+	// Hash the body while reading it into the buffer, so the body is only read once
+	hash := sha256.New()
+	var body bytes.Buffer
+	if r.ContentLength > 0 {
+		// The Content-Length is declared by the client and has not been verified yet,
+		// so the pre-allocation is capped; larger bodies still grow the buffer as they are read.
+		body.Grow(int(min(r.ContentLength, maxPayloadPrealloc)))
+	}
+	if _, err := body.ReadFrom(io.TeeReader(r.Body, hash)); err != nil {
 		return "", fmt.Errorf("failed to compute payload hash: %w", err)
 	}
-	// Replace the body with a new reader, because we already read it
-	r.Body = io.NopCloser(bytes.NewBuffer(body))
-	sum256 := sha256.Sum256(body)
-	return hex.EncodeToString(sum256[:]), nil
+	// Replace the body with a reader of the buffered bytes, because we already read it.
+	// GetBody is set as well, so that net/http can replay the body on redirects and retries.
+	buffered := body.Bytes()
+	r.Body = io.NopCloser(bytes.NewReader(buffered))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buffered)), nil
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
 // parseAuthorizationHeader parses the Authorization header of a http request according to:
 // https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Authorization
 func parseAuthorizationHeader(header string) (*authorization, error) {
 	if header == "" {
-		return nil, errors.New("missing authorization header")
+		return nil, newAuthError(AuthFailure_MissingHeader, "missing authorization header")
 	}
 	space := strings.IndexByte(header, ' ')
 	if space == -1 {
-		return nil, errors.New("invalid authorization header")
+		return nil, newAuthError(AuthFailure_BadScheme, "invalid authorization header")
 	}
 	scheme := header[:space]
 	parameters := strings.Split(header[space:], ",")
 	auth := authorization{
-		scheme: scheme,
+		scheme:  scheme,
+		version: signatureV1,
 	}
 	for _, p := range parameters {
 		kv := strings.Split(p, "=")
 		if len(kv) != 2 {
-			return nil, errors.New("invalid parameters in authorization header")
+			return nil, newAuthError(AuthFailure_BadScheme, "invalid parameters in authorization header")
 		}
 		kv[0] = strings.Trim(kv[0], ` `)
 		kv[1] = strings.Trim(kv[1], ` "`)
 		switch kv[0] {
 		case "Game":
 			auth.game = GameId(kv[1])
+		case "KeyId":
+			auth.keyId = kv[1]
+		case "Version":
+			v, err := strconv.Atoi(kv[1])
+			if err != nil || v < signatureV1 || v > signatureV2 {
+				return nil, newAuthError(AuthFailure_BadScheme, "unsupported signature version: %s", kv[1])
+			}
+			auth.version = v
+		case "SignedHeaders":
+			auth.signedHeaders = strings.Split(kv[1], ";")
 		case "Timestamp":
 			t, err := time.Parse(timeFormat, kv[1])
 			if err != nil {
-				return nil, fmt.Errorf("invalid timestamp: %w", err)
+				authErr := newAuthError(AuthFailure_BadTimestamp, "invalid timestamp: %v", err)
+				authErr.err = err
+				return nil, authErr
 			}
 			auth.timestamp = t
 		case "Signature":
@@ -169,5 +402,8 @@ func parseAuthorizationHeader(header string) (*authorization, error) {
 			// Ignore unknown authorization parameters
 		}
 	}
+	if auth.timestamp.IsZero() {
+		return nil, newAuthError(AuthFailure_BadTimestamp, "missing timestamp in authorization header")
+	}
 	return &auth, nil
 }
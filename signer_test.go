@@ -2,6 +2,8 @@ package combo
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
@@ -216,3 +218,172 @@ func TestBuildStringToSign(t *testing.T) {
 		t.Fatalf("expected fourth part to be %s, got %s", timestamp, parts[3])
 	}
 }
+
+func newTestSignerV2(headers ...string) *httpSigner {
+	signer := newTestSigner()
+	signer.signedHeaders = normalizeSignedHeaders(headers)
+	return signer
+}
+
+func TestSignAndAuthHttpV2(t *testing.T) {
+	signer := newTestSignerV2("Content-Type")
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/v1/server/test-api?b=2&a=1", bytes.NewBufferString(`{"key":"value"}`))
+	req.Header.Set("Content-Type", "application/json")
+	signingTime := time.Now()
+	if err := signer.SignHttp(req, signingTime); err != nil {
+		t.Fatalf("SignHttp failed: %v", err)
+	}
+
+	authHeader := req.Header.Get(authorizationHeader)
+	if !strings.Contains(authHeader, "Version=2") || !strings.Contains(authHeader, "SignedHeaders=content-type;host") {
+		t.Fatalf("unexpected authorization header: %s", authHeader)
+	}
+
+	// The verifier only knows the v1 defaults, the version is negotiated through the header.
+	verifier := newTestSigner()
+	auth, err := verifier.authenticate(req, signingTime)
+	if err != nil {
+		t.Fatalf("AuthHttp failed: %v", err)
+	}
+	if auth.version != signatureV2 {
+		t.Fatalf("expected version 2, got %d", auth.version)
+	}
+}
+
+func TestAuthHttpV2TamperedHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(r *http.Request)
+	}{
+		{
+			name:   "host",
+			tamper: func(r *http.Request) { r.Host = "evil.example.com" },
+		},
+		{
+			name:   "content type",
+			tamper: func(r *http.Request) { r.Header.Set("Content-Type", "text/plain") },
+		},
+		{
+			name:   "query",
+			tamper: func(r *http.Request) { r.URL.RawQuery = "a=1&b=3" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := newTestSignerV2("Content-Type")
+			req, _ := http.NewRequest(http.MethodPost, "https://example.com/test?a=1&b=2", bytes.NewBufferString(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			signingTime := time.Now()
+			_ = signer.SignHttp(req, signingTime)
+
+			tt.tamper(req)
+			err := signer.AuthHttp(req, signingTime)
+			if err == nil || !strings.Contains(err.Error(), "invalid signature") {
+				t.Fatalf("expected invalid signature error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthHttpV2CanonicalQuery(t *testing.T) {
+	signer := newTestSignerV2()
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/test?b=2&a=x+y&a=1", nil)
+	signingTime := time.Now()
+	_ = signer.SignHttp(req, signingTime)
+
+	// Reordered and differently encoded, but semantically identical query string.
+	req.URL.RawQuery = "a=1&a=x%20y&b=2"
+	if err := signer.AuthHttp(req, signingTime); err != nil {
+		t.Fatalf("AuthHttp failed: %v", err)
+	}
+}
+
+func TestAuthHttpV2InvalidQuery(t *testing.T) {
+	signer := newTestSignerV2()
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/test", nil)
+	signingTime := time.Now()
+	_ = signer.SignHttp(req, signingTime)
+
+	req.URL.RawQuery = "a=%zz"
+	err := signer.AuthHttp(req, signingTime)
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || authErr.Reason != AuthFailure_BadScheme {
+		t.Fatalf("expected bad scheme *AuthError, got %T: %v", err, err)
+	}
+	if !strings.Contains(err.Error(), "invalid query string") {
+		t.Fatalf("expected invalid query string error, got %v", err)
+	}
+}
+
+func TestAuthHttpMinVersion(t *testing.T) {
+	signer := newTestSigner()
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/test", bytes.NewBufferString(`{}`))
+	signingTime := time.Now()
+	_ = signer.SignHttp(req, signingTime)
+
+	verifier := newTestSigner()
+	verifier.minVersion = signatureV2
+	err := verifier.AuthHttp(req, signingTime)
+	if err == nil || !strings.Contains(err.Error(), "not accepted") {
+		t.Fatalf("expected version error, got %v", err)
+	}
+}
+
+func TestAuthHttpV2RequiresHost(t *testing.T) {
+	signer := newTestSigner()
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/test", bytes.NewBufferString(`{}`))
+	signingTime := time.Now()
+	req.Header.Set(authorizationHeader, "SEAYOO-HMAC-SHA256 Game=test_game,Version=2,SignedHeaders=content-type,Timestamp="+getTimestamp(signingTime)+",Signature=abc")
+	err := signer.AuthHttp(req, signingTime)
+	if err == nil || !strings.Contains(err.Error(), "must include host") {
+		t.Fatalf("expected host error, got %v", err)
+	}
+}
+
+func TestParseAuthorizationHeaderVersion(t *testing.T) {
+	auth, err := parseAuthorizationHeader("SEAYOO-HMAC-SHA256 Game=test,Version=2,SignedHeaders=content-type;host,Timestamp=20240115T120000Z,Signature=abc123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if auth.version != signatureV2 {
+		t.Fatalf("expected version 2, got %d", auth.version)
+	}
+	if strings.Join(auth.signedHeaders, ";") != "content-type;host" {
+		t.Fatalf("unexpected signed headers: %v", auth.signedHeaders)
+	}
+
+	if _, err := parseAuthorizationHeader("SEAYOO-HMAC-SHA256 Game=test,Version=3,Timestamp=20240115T120000Z,Signature=abc123"); err == nil {
+		t.Fatal("expected error for unsupported version")
+	}
+}
+
+func TestWithSignedHeadersRejectsInvalidNames(t *testing.T) {
+	for _, name := range []string{"Authorization", " authorization ", ""} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected panic for signed header %q", name)
+				}
+			}()
+			_, _ = NewClient(newTestConfig(), WithSignedHeaders("Content-Type", name))
+		})
+	}
+}
+
+func TestClientWithSignedHeaders(t *testing.T) {
+	client, err := NewClient(newTestConfig(), WithSignedHeaders("Content-Type", "User-Agent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := client.newHttpRequest(context.Background(), "test-api", map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(req.Header.Get(authorizationHeader), "SignedHeaders=content-type;host;user-agent") {
+		t.Fatalf("unexpected authorization header: %s", req.Header.Get(authorizationHeader))
+	}
+	verifier := &httpSigner{game: testGameId, signingKey: SecretKey(testSecretKey)}
+	if err := verifier.AuthHttp(req, time.Now()); err != nil {
+		t.Fatalf("AuthHttp failed: %v", err)
+	}
+}
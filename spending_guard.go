@@ -0,0 +1,396 @@
+package combo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SpendingBracket 是一个年龄段的未成年人充值限制。金额单位均为分。
+type SpendingBracket struct {
+	// 该年龄段的年龄上限（不含）。例如 MaxAge 为 16 表示适用于 16 岁以下的用户。
+	MaxAge int
+
+	// 单次充值金额的上限。为 0 表示该年龄段不允许充值。
+	SingleLimit int
+
+	// 每月累计充值金额的上限。为 0 表示该年龄段不允许充值。
+	MonthlyLimit int
+}
+
+// DefaultSpendingBrackets 返回国家规定的未成年人充值限制：
+//   - 未满 8 周岁的用户不允许充值。
+//   - 8 周岁以上未满 16 周岁的用户，单次充值不超过 50 元，每月累计不超过 200 元。
+//   - 16 周岁以上未满 18 周岁的用户，单次充值不超过 100 元，每月累计不超过 400 元。
+func DefaultSpendingBrackets() []SpendingBracket {
+	return []SpendingBracket{
+		{MaxAge: 8, SingleLimit: 0, MonthlyLimit: 0},
+		{MaxAge: 16, SingleLimit: 5000, MonthlyLimit: 20000},
+		{MaxAge: 18, SingleLimit: 10000, MonthlyLimit: 40000},
+	}
+}
+
+// SpendingReason 是充值被 SpendingGuard 拒绝的原因。
+type SpendingReason string
+
+const (
+	// 用户未实名认证 (Age 为 0)，不允许充值。
+	Spending_Unverified SpendingReason = "unverified"
+
+	// 用户所在的年龄段不允许充值。
+	Spending_UnderAge SpendingReason = "under_age"
+
+	// 单次充值金额超出上限。
+	Spending_SingleLimitExceeded SpendingReason = "single_limit_exceeded"
+
+	// 本月累计充值金额超出上限。
+	Spending_MonthlyLimitExceeded SpendingReason = "monthly_limit_exceeded"
+
+	// 充值的币种不是人民币，无法按照未成年人充值限制判定。
+	Spending_UnsupportedCurrency SpendingReason = "unsupported_currency"
+)
+
+// SpendingGuard 只统计和判定人民币充值。
+const spendingCurrency = "CNY"
+
+// SpendingDecision 是 SpendingGuard 对一次充值的判定结果。金额单位均为分。
+type SpendingDecision struct {
+	// 是否允许充值。
+	Allowed bool
+
+	// 不允许充值的原因。仅在 Allowed 为 false 时有值。
+	Reason SpendingReason
+
+	// 用户所在年龄段的单次充值上限和每月累计充值上限。成年用户不受限制，此时为 0。
+	SingleLimit  int
+	MonthlyLimit int
+
+	// 用户本月已经累计充值的金额。仅在用户所在年龄段有每月累计充值上限时有值。
+	MonthlySpent int
+}
+
+// SpendingGuardConfig 包含了创建 SpendingGuard 时所必需的配置项。
+type SpendingGuardConfig struct {
+	Ledger   SpendingLedger    // 充值记录的存储。实现可以是 Redis 或 Memory，也可以自行实现 SpendingLedger
+	Brackets []SpendingBracket // 各年龄段的充值限制，按 MaxAge 从小到大排列。如果不指定，则默认为 DefaultSpendingBrackets()
+	Clock    Clock             // 用于获取当前时间，如果不指定，则默认为 SystemClock
+}
+
+// SpendingGuard 用于在创建订单之前，根据未成年人充值限制判定是否允许充值。
+//
+// 月度累计金额来自 SpendingLedger，游戏侧需要使用 SpendingGuard.NotificationListener 包装 NotificationListener，
+// 以便在收到发货通知时记录充值，在收到退款通知时撤销充值。
+//
+// 推荐的充值流程如下：
+//  1. 调用 CheckPurchase 判定是否允许充值。
+//  2. 调用 Client.CreateOrder 创建订单。
+//  3. 调用 ReservePurchase 记录订单，使订单计入创建订单时所在月份，并且在发货之前就计入月度累计金额。
+//
+// 注意：CheckPurchase 和 ReservePurchase 之间没有加锁。同一个用户并发充值时，多个请求可能都通过 CheckPurchase，
+// 使月度累计金额超出上限。如果需要严格保证上限，游戏侧应当对同一个用户的充值请求串行处理。
+//
+// 注意：月份按照北京时间划分，金额单位为人民币分。币种不是 CNY 的订单不会计入月度累计金额。
+type SpendingGuard struct {
+	ledger   SpendingLedger
+	brackets []SpendingBracket
+	clock    Clock
+}
+
+// NewSpendingGuard 创建一个 SpendingGuard。
+func NewSpendingGuard(cfg SpendingGuardConfig) *SpendingGuard {
+	if cfg.Ledger == nil {
+		panic("missing required cfg.Ledger")
+	}
+	if cfg.Brackets == nil {
+		cfg.Brackets = DefaultSpendingBrackets()
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = SystemClock
+	}
+	return &SpendingGuard{
+		ledger:   cfg.Ledger,
+		brackets: cfg.Brackets,
+		clock:    cfg.Clock,
+	}
+}
+
+// CheckPurchase 判定用户是否允许以 currency 币种充值 amount（最小货币单位）。游戏侧应当在调用 Client.CreateOrder 之前调用。
+//
+// 成年用户不受限制。未成年用户只允许以 CNY 充值，其他币种会以 Spending_UnsupportedCurrency 拒绝。
+func (g *SpendingGuard) CheckPurchase(ctx context.Context, identity *IdentityPayload, currency string, amount int) (SpendingDecision, error) {
+	if identity.Age <= 0 {
+		return SpendingDecision{Reason: Spending_Unverified}, nil
+	}
+	bracket, ok := g.bracket(identity.Age)
+	if !ok {
+		return SpendingDecision{Allowed: true}, nil
+	}
+	decision := SpendingDecision{
+		SingleLimit:  bracket.SingleLimit,
+		MonthlyLimit: bracket.MonthlyLimit,
+	}
+	if currency != spendingCurrency {
+		decision.Reason = Spending_UnsupportedCurrency
+		return decision, nil
+	}
+	if bracket.SingleLimit <= 0 || bracket.MonthlyLimit <= 0 {
+		decision.Reason = Spending_UnderAge
+		return decision, nil
+	}
+	if amount > bracket.SingleLimit {
+		decision.Reason = Spending_SingleLimitExceeded
+		return decision, nil
+	}
+	spent, err := g.ledger.MonthlyTotal(ctx, identity.ComboId, g.month())
+	if err != nil {
+		return SpendingDecision{}, err
+	}
+	decision.MonthlySpent = spent
+	if spent+amount > bracket.MonthlyLimit {
+		decision.Reason = Spending_MonthlyLimitExceeded
+		return decision, nil
+	}
+	decision.Allowed = true
+	return decision, nil
+}
+
+// ReservePurchase 在创建订单之后记录订单，currency 和 amount 应当和创建订单时的一致。游戏侧应当在 Client.CreateOrder 成功之后调用。
+//
+// 订单按照调用时（即创建订单时）的月份计入月度累计金额，之后收到发货通知时不会重复记录。
+// 所以在月末创建、次月发货的订单仍然计入创建订单时的月份，并且尚未发货的订单也会被后续的 CheckPurchase 计入。
+//
+// 未支付的订单同样会计入月度累计金额。游戏侧可以在订单过期未支付时调用 ReleasePurchase 撤销记录。
+func (g *SpendingGuard) ReservePurchase(ctx context.Context, comboId, orderId, currency string, amount int) error {
+	if currency != spendingCurrency {
+		return nil
+	}
+	return g.ledger.Record(ctx, comboId, g.month(), orderId, amount)
+}
+
+// ReleasePurchase 撤销 ReservePurchase 记录的订单，用于订单过期未支付的场景。
+func (g *SpendingGuard) ReleasePurchase(ctx context.Context, comboId, orderId string) error {
+	return g.ledger.Reverse(ctx, comboId, orderId)
+}
+
+// NotificationListener 包装游戏侧的 NotificationListener，在收到发货通知时记录充值，在收到退款通知时撤销充值。
+//
+// 记录充值在调用 next 之前进行，并且同一个订单只会记录一次，所以世游服务端重试推送通知时不会重复记录。
+// 已经通过 ReservePurchase 记录的订单保持创建订单时的月份；否则按照收到发货通知时的月份记录。
+// 币种不是 CNY 的订单不会被记录，也不会被撤销。
+func (g *SpendingGuard) NotificationListener(next NotificationListener) NotificationListener {
+	return &spendingNotificationListener{guard: g, next: next}
+}
+
+func (g *SpendingGuard) bracket(age int) (SpendingBracket, bool) {
+	for _, b := range g.brackets {
+		if age < b.MaxAge {
+			return b, true
+		}
+	}
+	return SpendingBracket{}, false
+}
+
+func (g *SpendingGuard) month() string {
+	return g.clock.Now().In(chinaTime).Format("200601")
+}
+
+type spendingNotificationListener struct {
+	guard *SpendingGuard
+	next  NotificationListener
+}
+
+// HandleShipOrder implements NotificationListener.
+func (l *spendingNotificationListener) HandleShipOrder(ctx context.Context, id NotificationId, payload *ShipOrderNotification) error {
+	if payload.Currency != spendingCurrency {
+		return l.next.HandleShipOrder(ctx, id, payload)
+	}
+	if err := l.guard.ledger.Record(ctx, payload.ComboId, l.guard.month(), payload.OrderId, payload.Amount); err != nil {
+		return fmt.Errorf("error recording spending: %w", err)
+	}
+	return l.next.HandleShipOrder(ctx, id, payload)
+}
+
+// HandleRefund implements NotificationListener.
+func (l *spendingNotificationListener) HandleRefund(ctx context.Context, id NotificationId, payload *RefundNotification) error {
+	if payload.Currency != spendingCurrency {
+		return l.next.HandleRefund(ctx, id, payload)
+	}
+	if err := l.guard.ledger.Reverse(ctx, payload.ComboId, payload.OrderId); err != nil {
+		return fmt.Errorf("error reversing spending: %w", err)
+	}
+	return l.next.HandleRefund(ctx, id, payload)
+}
+
+// SpendingLedger 是一个用于存储用户充值记录的接口，用于统计用户每月的累计充值金额。
+//
+// Combo SDK 内置了 Redis 和 Memory 两种实现，可分别通过 NewMemorySpendingLedger() 和 NewRedisSpendingLedger() 创建。
+//
+// 游戏侧也可以选择自行实现 SpendingLedger 接口。
+type SpendingLedger interface {
+	// Record 用于记录 comboId 在 month（格式为 "200601"）的一笔充值。
+	// 同一个 orderId 只会被记录一次，重复记录会被忽略。
+	Record(ctx context.Context, comboId, month, orderId string, amount int) error
+
+	// Reverse 用于撤销 orderId 对应的充值，从充值所在月份的累计金额中扣除。
+	// 如果 orderId 没有被记录过，或者已经被撤销过，则忽略。
+	Reverse(ctx context.Context, comboId, orderId string) error
+
+	// MonthlyTotal 返回 comboId 在 month（格式为 "200601"）的累计充值金额。
+	MonthlyTotal(ctx context.Context, comboId, month string) (int, error)
+}
+
+// NewMemorySpendingLedger 创建一个基于 Memory 的 SpendingLedger 实现。
+//
+// 注意：该实现仅用于开发调试，不适合生产环境。
+//
+// 数据仅在内存中存储，重启服务后数据会丢失。数据不会过期，不会自动清理。
+func NewMemorySpendingLedger() SpendingLedger {
+	return &memorySpendingLedger{
+		orders: make(map[string]*memorySpendingOrder),
+		totals: make(map[string]int),
+	}
+}
+
+// NewRedisSpendingLedger 创建一个基于 Redis 的 SpendingLedger 实现。
+//
+// 数据会存储在 Redis 中，可以在多个游戏服务实例之间共享，并且到期自动清理。推荐生产环境使用。
+func NewRedisSpendingLedger(cfg RedisSpendingLedgerConfig) SpendingLedger {
+	if cfg.Client == nil {
+		panic("missing required cfg.Client")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 90 * 24 * time.Hour
+	}
+	return &redisSpendingLedger{
+		client: cfg.Client,
+		ttl:    cfg.TTL,
+		prefix: cfg.Prefix,
+	}
+}
+
+// RedisSpendingLedgerConfig 包含了创建基于 Redis 的 SpendingLedger 时所必需的配置项。
+type RedisSpendingLedgerConfig struct {
+	Client redis.Cmdable // Redis 客户端。这里不假设 Redis 的运维部署方式。可以是 redis.Client 或者 redis.ClusterClient，由游戏侧自行创建和配置。
+	TTL    time.Duration // 充值记录的过期时间，应当长于退款通知可能到达的最长时间。如果不指定，则默认为 90 天。
+	Prefix string        // Key 的前缀，如果不指定，则默认为空字符串。
+}
+
+type memorySpendingOrder struct {
+	month    string
+	amount   int
+	reversed bool
+}
+
+type memorySpendingLedger struct {
+	mu     sync.Mutex
+	orders map[string]*memorySpendingOrder
+	totals map[string]int
+}
+
+// Record implements SpendingLedger.
+func (l *memorySpendingLedger) Record(ctx context.Context, comboId, month, orderId string, amount int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := comboId + ":" + orderId
+	if _, ok := l.orders[key]; ok {
+		return nil
+	}
+	l.orders[key] = &memorySpendingOrder{month: month, amount: amount}
+	l.totals[comboId+":"+month] += amount
+	return nil
+}
+
+// Reverse implements SpendingLedger.
+func (l *memorySpendingLedger) Reverse(ctx context.Context, comboId, orderId string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	order, ok := l.orders[comboId+":"+orderId]
+	if !ok || order.reversed {
+		return nil
+	}
+	order.reversed = true
+	l.totals[comboId+":"+order.month] -= order.amount
+	return nil
+}
+
+// MonthlyTotal implements SpendingLedger.
+func (l *memorySpendingLedger) MonthlyTotal(ctx context.Context, comboId, month string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.totals[comboId+":"+month], nil
+}
+
+// 订单记录的值为 "month:amount"，撤销后追加 ":reversed"。
+var spendingRecordScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1] .. ":" .. ARGV[2], "NX", "PX", ARGV[3]) then
+	redis.call("INCRBY", KEYS[2], ARGV[2])
+	redis.call("PEXPIRE", KEYS[2], ARGV[3])
+end
+return 0
+`)
+
+var spendingReverseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[1] .. ":reversed", "KEEPTTL")
+	redis.call("DECRBY", KEYS[2], ARGV[2])
+end
+return 0
+`)
+
+type redisSpendingLedger struct {
+	client redis.Cmdable
+	ttl    time.Duration
+	prefix string
+}
+
+// 同一个用户的 key 使用相同的 hash tag，以保证 Lua 脚本在 Redis Cluster 中访问的 key 位于同一个 slot。
+func (l *redisSpendingLedger) orderKey(comboId, orderId string) string {
+	return l.prefix + "spending:{" + comboId + "}:order:" + orderId
+}
+
+func (l *redisSpendingLedger) totalKey(comboId, month string) string {
+	return l.prefix + "spending:{" + comboId + "}:month:" + month
+}
+
+// Record implements SpendingLedger.
+func (l *redisSpendingLedger) Record(ctx context.Context, comboId, month, orderId string, amount int) error {
+	keys := []string{l.orderKey(comboId, orderId), l.totalKey(comboId, month)}
+	return spendingRecordScript.Run(ctx, l.client, keys, month, amount, l.ttl.Milliseconds()).Err()
+}
+
+// Reverse implements SpendingLedger.
+func (l *redisSpendingLedger) Reverse(ctx context.Context, comboId, orderId string) error {
+	orderKey := l.orderKey(comboId, orderId)
+	value, err := l.client.Get(ctx, orderKey).Result()
+	if err == redis.Nil {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		// 已经撤销过了。
+		return nil
+	}
+	month := parts[0]
+	amount, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid spending record %q: %w", value, err)
+	}
+	keys := []string{orderKey, l.totalKey(comboId, month)}
+	return spendingReverseScript.Run(ctx, l.client, keys, value, amount).Err()
+}
+
+// MonthlyTotal implements SpendingLedger.
+func (l *redisSpendingLedger) MonthlyTotal(ctx context.Context, comboId, month string) (int, error) {
+	total, err := l.client.Get(ctx, l.totalKey(comboId, month)).Int()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return total, err
+}
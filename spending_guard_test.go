@@ -0,0 +1,210 @@
+package combo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestSpendingGuard(ledger SpendingLedger, now *time.Time) *SpendingGuard {
+	return NewSpendingGuard(SpendingGuardConfig{
+		Ledger: ledger,
+		Clock:  ClockFunc(func() time.Time { return *now }),
+	})
+}
+
+func TestSpendingGuardCheckPurchase(t *testing.T) {
+	now := chinaDate(2024, 1, 15, 12, 0)
+	guard := newTestSpendingGuard(NewMemorySpendingLedger(), &now)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		age         int
+		amount      int
+		wantAllowed bool
+		wantReason  SpendingReason
+	}{
+		{"unverified", 0, 100, false, Spending_Unverified},
+		{"under 8", 7, 100, false, Spending_UnderAge},
+		{"8 to 16 within limit", 8, 5000, true, ""},
+		{"8 to 16 single limit", 15, 5001, false, Spending_SingleLimitExceeded},
+		{"16 to 18 within limit", 16, 10000, true, ""},
+		{"16 to 18 single limit", 17, 10001, false, Spending_SingleLimitExceeded},
+		{"adult", 18, 1000000, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := guard.CheckPurchase(ctx, &IdentityPayload{ComboId: "combo_1", Age: tt.age}, "CNY", tt.amount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Allowed != tt.wantAllowed || d.Reason != tt.wantReason {
+				t.Fatalf("expected allowed=%v reason=%q, got %+v", tt.wantAllowed, tt.wantReason, d)
+			}
+		})
+	}
+}
+
+func TestSpendingGuardMonthlyLimit(t *testing.T) {
+	now := chinaDate(2024, 1, 31, 23, 0)
+	ledger := NewMemorySpendingLedger()
+	guard := newTestSpendingGuard(ledger, &now)
+	listener := &mockNotificationListener{}
+	wrapped := guard.NotificationListener(listener)
+	ctx := context.Background()
+	minor := &IdentityPayload{ComboId: "combo_1", Age: 12}
+
+	for i, orderId := range []string{"order_1", "order_2", "order_3", "order_4"} {
+		if err := wrapped.HandleShipOrder(ctx, NotificationId("n"+orderId), &ShipOrderNotification{
+			OrderId:  orderId,
+			ComboId:  "combo_1",
+			Currency: "CNY",
+			Amount:   5000,
+		}); err != nil {
+			t.Fatalf("order %d: %v", i, err)
+		}
+	}
+	// Retried notifications are recorded only once.
+	_ = wrapped.HandleShipOrder(ctx, "retry", &ShipOrderNotification{OrderId: "order_4", ComboId: "combo_1", Currency: "CNY", Amount: 5000})
+	if !listener.shipOrderCalled {
+		t.Fatal("expected the wrapped listener to be called")
+	}
+
+	d, err := guard.CheckPurchase(ctx, minor, "CNY", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed || d.Reason != Spending_MonthlyLimitExceeded || d.MonthlySpent != 20000 {
+		t.Fatalf("expected monthly limit to be reached, got %+v", d)
+	}
+
+	// A refund frees up the monthly quota.
+	if err := wrapped.HandleRefund(ctx, "refund", &RefundNotification{OrderId: "order_4", ComboId: "combo_1", Currency: "CNY", Amount: 5000}); err != nil {
+		t.Fatal(err)
+	}
+	_ = wrapped.HandleRefund(ctx, "refund_retry", &RefundNotification{OrderId: "order_4", ComboId: "combo_1", Currency: "CNY", Amount: 5000})
+	if d, _ := guard.CheckPurchase(ctx, minor, "CNY", 5000); !d.Allowed || d.MonthlySpent != 15000 {
+		t.Fatalf("expected purchase to be allowed after refund, got %+v", d)
+	}
+
+	// The monthly total resets in the next month (Beijing time).
+	now = now.Add(time.Hour)
+	if d, _ := guard.CheckPurchase(ctx, minor, "CNY", 5000); !d.Allowed || d.MonthlySpent != 0 {
+		t.Fatalf("expected monthly total to reset, got %+v", d)
+	}
+}
+
+func TestSpendingGuardReservePurchase(t *testing.T) {
+	now := chinaDate(2024, 1, 31, 23, 0)
+	guard := newTestSpendingGuard(NewMemorySpendingLedger(), &now)
+	wrapped := guard.NotificationListener(&mockNotificationListener{})
+	ctx := context.Background()
+	minor := &IdentityPayload{ComboId: "combo_1", Age: 12}
+
+	if err := guard.ReservePurchase(ctx, "combo_1", "order_1", "CNY", 5000); err != nil {
+		t.Fatal(err)
+	}
+	// Orders that are created but not yet shipped are counted.
+	if d, _ := guard.CheckPurchase(ctx, minor, "CNY", 100); d.MonthlySpent != 5000 {
+		t.Fatalf("expected reserved order to be counted, got %+v", d)
+	}
+
+	// Shipped after midnight, the order still counts against the month it was created in.
+	now = now.Add(90 * time.Minute)
+	if err := wrapped.HandleShipOrder(ctx, "n1", &ShipOrderNotification{OrderId: "order_1", ComboId: "combo_1", Currency: "CNY", Amount: 5000}); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := guard.CheckPurchase(ctx, minor, "CNY", 100); d.MonthlySpent != 0 {
+		t.Fatalf("expected order to be counted in the month it was created, got %+v", d)
+	}
+
+	// Unpaid orders can be released.
+	if err := guard.ReservePurchase(ctx, "combo_1", "order_2", "CNY", 5000); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.ReleasePurchase(ctx, "combo_1", "order_2"); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := guard.CheckPurchase(ctx, minor, "CNY", 100); d.MonthlySpent != 0 {
+		t.Fatalf("expected released order not to be counted, got %+v", d)
+	}
+}
+
+func TestSpendingGuardIgnoresNonCNY(t *testing.T) {
+	now := chinaDate(2024, 1, 15, 12, 0)
+	guard := newTestSpendingGuard(NewMemorySpendingLedger(), &now)
+	listener := &mockNotificationListener{}
+	wrapped := guard.NotificationListener(listener)
+	ctx := context.Background()
+	minor := &IdentityPayload{ComboId: "combo_1", Age: 12}
+
+	if err := wrapped.HandleShipOrder(ctx, "n1", &ShipOrderNotification{
+		OrderId:  "order_1",
+		ComboId:  "combo_1",
+		Currency: "USD",
+		Amount:   99999,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !listener.shipOrderCalled {
+		t.Fatal("expected the wrapped listener to be called")
+	}
+	if d, _ := guard.CheckPurchase(ctx, minor, "CNY", 5000); !d.Allowed || d.MonthlySpent != 0 {
+		t.Fatalf("expected non-CNY order not to be recorded, got %+v", d)
+	}
+
+	if d, _ := guard.CheckPurchase(ctx, minor, "USD", 100); d.Allowed || d.Reason != Spending_UnsupportedCurrency {
+		t.Fatalf("expected non-CNY purchase to be rejected for minors, got %+v", d)
+	}
+	if d, _ := guard.CheckPurchase(ctx, &IdentityPayload{ComboId: "combo_2", Age: 30}, "USD", 100); !d.Allowed {
+		t.Fatalf("expected non-CNY purchase to be allowed for adults, got %+v", d)
+	}
+}
+
+func TestSpendingGuardCustomBrackets(t *testing.T) {
+	now := chinaDate(2024, 1, 15, 12, 0)
+	guard := NewSpendingGuard(SpendingGuardConfig{
+		Ledger:   NewMemorySpendingLedger(),
+		Brackets: []SpendingBracket{{MaxAge: 18, SingleLimit: 100, MonthlyLimit: 100}},
+		Clock:    ClockFunc(func() time.Time { return now }),
+	})
+	if d, _ := guard.CheckPurchase(context.Background(), &IdentityPayload{Age: 6}, "CNY", 100); !d.Allowed {
+		t.Fatalf("expected custom bracket to allow purchase, got %+v", d)
+	}
+}
+
+func TestRedisSpendingLedger(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ledger := NewRedisSpendingLedger(RedisSpendingLedgerConfig{Client: client, Prefix: "game:"})
+	ctx := context.Background()
+
+	_ = ledger.Record(ctx, "combo_1", "202401", "order_1", 3000)
+	_ = ledger.Record(ctx, "combo_1", "202401", "order_1", 3000)
+	_ = ledger.Record(ctx, "combo_1", "202401", "order_2", 2000)
+	if total, err := ledger.MonthlyTotal(ctx, "combo_1", "202401"); err != nil || total != 5000 {
+		t.Fatalf("expected 5000, got %d, err=%v", total, err)
+	}
+	if ttl := mr.TTL("game:spending:{combo_1}:order:order_1"); ttl != 90*24*time.Hour {
+		t.Fatalf("expected order record ttl to be 90 days, got %v", ttl)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := ledger.Reverse(ctx, "combo_1", "order_1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ledger.Reverse(ctx, "combo_1", "order_missing"); err != nil {
+		t.Fatal(err)
+	}
+	if total, _ := ledger.MonthlyTotal(ctx, "combo_1", "202401"); total != 2000 {
+		t.Fatalf("expected 2000 after reversal, got %d", total)
+	}
+	if total, _ := ledger.MonthlyTotal(ctx, "combo_2", "202401"); total != 0 {
+		t.Fatalf("expected 0 for unknown user, got %d", total)
+	}
+}
@@ -0,0 +1,138 @@
+package combo
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// WithTokenCache 用于开启 Identity Token 的验证结果缓存，size 是最多缓存的 Token 数量。
+//
+// 开启后，验证通过的 Identity Token 的解析结果会以 Token 的 SHA-256 哈希为 key 缓存在内存中，直到 Token 过期。
+// 再次验证同一个 Token 时，会跳过 JWT 解析、签名验证和 weixin_session_key 解密。
+// 缓存满了以后，最久未使用的 Token 会被淘汰。
+//
+// 注意：命中缓存时，WithMaxTokenAge、WithIdentityPolicy 等验证策略，以及吊销检查和一次性使用检查仍然会执行。
+func WithTokenCache(size int) VerifierOption {
+	return func(v *TokenVerifier) {
+		if size > 0 {
+			v.cache = newTokenCache(size)
+		} else {
+			v.cache = nil
+		}
+	}
+}
+
+// TokenCacheStats 是 Token 缓存的统计信息。
+type TokenCacheStats struct {
+	// 命中缓存的次数。
+	Hits uint64
+
+	// 未命中缓存的次数，包括缓存的 Token 已过期的情况。
+	Misses uint64
+
+	// 因为缓存已满而被淘汰的 Token 数量。
+	Evictions uint64
+
+	// 当前缓存的 Token 数量。
+	Size int
+}
+
+// CacheStats 返回 Token 缓存的统计信息。如果没有通过 WithTokenCache 开启缓存，则返回零值。
+func (v *TokenVerifier) CacheStats() TokenCacheStats {
+	return v.cache.stats()
+}
+
+type tokenCacheEntry struct {
+	key       [sha256.Size]byte
+	claims    *identityClaims
+	payload   IdentityPayload
+	expiresAt time.Time
+}
+
+// tokenCache 是一个并发安全的 LRU 缓存。
+type tokenCache struct {
+	mu        sync.Mutex
+	size      int
+	ll        *list.List
+	items     map[[sha256.Size]byte]*list.Element
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+func newTokenCache(size int) *tokenCache {
+	return &tokenCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// get 从缓存中获取 Token 的解析结果。返回的 IdentityPayload 是缓存的副本，调用方可以随意修改。
+func (c *tokenCache) get(tokenString string, now time.Time) (*identityClaims, *IdentityPayload, bool) {
+	if c == nil {
+		return nil, nil, false
+	}
+	key := sha256.Sum256([]byte(tokenString))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, nil, false
+	}
+	entry := elem.Value.(*tokenCacheEntry)
+	if !now.Before(entry.expiresAt) {
+		c.ll.Remove(elem)
+		delete(c.items, key)
+		c.misses++
+		return nil, nil, false
+	}
+	c.ll.MoveToFront(elem)
+	c.hits++
+	payload := entry.payload
+	return entry.claims, &payload, true
+}
+
+// add 将 Token 的解析结果加入缓存，缓存在 expiresAt 之后失效。
+func (c *tokenCache) add(tokenString string, claims *identityClaims, payload *IdentityPayload, expiresAt time.Time) {
+	if c == nil {
+		return
+	}
+	key := sha256.Sum256([]byte(tokenString))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.ll.MoveToFront(elem)
+		return
+	}
+	entry := &tokenCacheEntry{
+		key:       key,
+		claims:    claims,
+		payload:   *payload,
+		expiresAt: expiresAt,
+	}
+	c.items[key] = c.ll.PushFront(entry)
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*tokenCacheEntry).key)
+		c.evictions++
+	}
+}
+
+func (c *tokenCache) stats() TokenCacheStats {
+	if c == nil {
+		return TokenCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return TokenCacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Size:      c.ll.Len(),
+	}
+}
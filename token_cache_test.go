@@ -0,0 +1,133 @@
+package combo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifierWithTokenCache(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now, WithTokenCache(10))
+
+	claims := newTestIdentityClaims(now)
+	claims.WeixinSessionKey = encryptSessionKey(t, SecretKey(testSecretKey), "session_key")
+	tokenString := signToken(t, claims)
+
+	first, err := v.VerifyIdentityToken(tokenString)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first.ComboId = "mutated"
+
+	second, err := v.VerifyIdentityToken(tokenString)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.ComboId != "combo_123" || second.WeixinSessionKey != "session_key" {
+		t.Fatalf("unexpected cached payload: %+v", second)
+	}
+
+	stats := v.CacheStats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Size != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestVerifierTokenCacheDoesNotCacheInvalidTokens(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now, WithTokenCache(10))
+
+	claims := newTestIdentityClaims(now)
+	claims.Scope = "ads"
+	tokenString := signToken(t, claims)
+	for i := 0; i < 2; i++ {
+		if _, err := v.VerifyIdentityToken(tokenString); err == nil {
+			t.Fatal("expected error for invalid scope")
+		}
+	}
+	if stats := v.CacheStats(); stats.Hits != 0 || stats.Size != 0 {
+		t.Fatalf("expected invalid token not to be cached, got %+v", stats)
+	}
+}
+
+func TestVerifierTokenCacheExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	cfg := newTestConfig()
+	cfg.Clock = ClockFunc(func() time.Time { return now })
+	v, err := NewTokenVerifier(cfg, WithTokenCache(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenString := signToken(t, newTestIdentityClaims(now))
+	if _, err := v.VerifyIdentityToken(tokenString); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := v.VerifyIdentityToken(tokenString); err == nil {
+		t.Fatal("expected error for expired cached token")
+	}
+	if stats := v.CacheStats(); stats.Hits != 0 || stats.Misses != 2 || stats.Size != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestVerifierTokenCacheEviction(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now, WithTokenCache(2))
+
+	tokens := make([]string, 3)
+	for i, comboId := range []string{"combo_1", "combo_2", "combo_3"} {
+		claims := newTestIdentityClaims(now)
+		claims.Subject = comboId
+		tokens[i] = signToken(t, claims)
+	}
+	_, _ = v.VerifyIdentityToken(tokens[0])
+	_, _ = v.VerifyIdentityToken(tokens[1])
+	_, _ = v.VerifyIdentityToken(tokens[0]) // tokens[1] becomes the least recently used.
+	_, _ = v.VerifyIdentityToken(tokens[2])
+
+	stats := v.CacheStats()
+	if stats.Evictions != 1 || stats.Size != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	_, _ = v.VerifyIdentityToken(tokens[0])
+	if got := v.CacheStats().Hits; got != stats.Hits+1 {
+		t.Fatalf("expected tokens[0] to stay cached, hits %d -> %d", stats.Hits, got)
+	}
+	_, _ = v.VerifyIdentityToken(tokens[1])
+	if got := v.CacheStats().Misses; got != stats.Misses+1 {
+		t.Fatalf("expected tokens[1] to be evicted, misses %d -> %d", stats.Misses, got)
+	}
+}
+
+func TestVerifierTokenCacheStillChecksRevocation(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryRevocationStore()
+	v := newTestVerifierWithOptions(t, now, WithTokenCache(10), WithRevocationStore(store))
+
+	claims := newTestIdentityClaims(now)
+	claims.ID = "jti_1"
+	tokenString := signToken(t, claims)
+	if _, err := v.VerifyIdentityToken(tokenString); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_ = store.RevokeTokenId(context.Background(), "jti_1", time.Hour)
+	if _, err := v.VerifyIdentityToken(tokenString); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected ErrTokenRevoked on cache hit, got %v", err)
+	}
+	if hits := v.CacheStats().Hits; hits != 1 {
+		t.Fatalf("expected revocation to be checked on a cache hit, got %d hits", hits)
+	}
+}
+
+func TestVerifierCacheStatsWithoutCache(t *testing.T) {
+	v := newTestVerifier(t)
+	if stats := v.CacheStats(); stats != (TokenCacheStats{}) {
+		t.Fatalf("expected zero stats, got %+v", stats)
+	}
+}
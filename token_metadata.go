@@ -0,0 +1,150 @@
+package combo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tokenMetadata 包含了 Token 的标准声明和 SDK 尚不认识的声明，通过 IdentityPayload 和 AdPayload 的方法访问。
+//
+// tokenMetadata 的字段均不导出，因此不会被 json.Marshal 序列化。
+type tokenMetadata struct {
+	issuedAt  time.Time
+	expiresAt time.Time
+	notBefore time.Time
+	tokenId   string
+	// extra 是 SDK 尚不认识的声明组成的 JSON object。
+	// 这里存储为 string 而不是 map，以便 IdentityPayload 和 AdPayload 仍然可以使用 == 比较，或者作为 map 的 key。
+	extra string
+}
+
+func newTokenMetadata(claims *jwt.RegisteredClaims) tokenMetadata {
+	m := tokenMetadata{tokenId: claims.ID}
+	if claims.IssuedAt != nil {
+		m.issuedAt = claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		m.expiresAt = claims.ExpiresAt.Time
+	}
+	if claims.NotBefore != nil {
+		m.notBefore = claims.NotBefore.Time
+	}
+	return m
+}
+
+// IssuedAt 返回 Token 的签发时间 (iat)。如果 Token 不包含 iat，则返回零值。
+func (m tokenMetadata) IssuedAt() time.Time {
+	return m.issuedAt
+}
+
+// ExpiresAt 返回 Token 的过期时间 (exp)。
+func (m tokenMetadata) ExpiresAt() time.Time {
+	return m.expiresAt
+}
+
+// NotBefore 返回 Token 的生效时间 (nbf)。如果 Token 不包含 nbf，则返回零值。
+func (m tokenMetadata) NotBefore() time.Time {
+	return m.notBefore
+}
+
+// TokenId 返回 Token 的唯一 ID (jti)。如果 Token 不包含 jti，则返回空字符串。
+func (m tokenMetadata) TokenId() string {
+	return m.tokenId
+}
+
+// Extra 返回 Token 中 SDK 尚不认识的声明，key 是声明的名称，value 是声明的原始 JSON。如果没有这样的声明，则返回 nil。
+//
+// 当世游服务端新增了声明时，游戏侧无需等待 SDK 发布新版本，即可通过 Extra 或 VerifyIdentityTokenInto 获取。
+// 每次调用都会返回一个新的 map，调用方可以随意修改。
+func (m tokenMetadata) Extra() map[string]json.RawMessage {
+	if m.extra == "" {
+		return nil
+	}
+	var extra map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(m.extra), &extra); err != nil {
+		return nil
+	}
+	return extra
+}
+
+var registeredClaimNames = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti"}
+
+var identityClaimNames = append([]string{
+	"scope", "idp", "external_id", "external_name", "weixin_session_key",
+	"device_id", "distro", "variant", "age", "reg_time",
+}, registeredClaimNames...)
+
+var adClaimNames = append([]string{
+	"scope", "placement_id", "impression_id",
+}, registeredClaimNames...)
+
+// VerifyIdentityTokenInto 对 IdentityToken 进行验证，并将 Token 的全部声明解码到 dst 中。
+//
+// dst 必须是指针，通常是游戏侧自定义的结构体，用于以强类型的方式访问 SDK 尚未支持的新声明：
+//
+//	var claims struct {
+//	    NewField string `json:"new_field"`
+//	}
+//	payload, err := verifier.VerifyIdentityTokenInto(token, &claims)
+//
+// 验证规则和 VerifyIdentityToken 完全相同。如果验证不通过，dst 不会被修改。
+func (v *TokenVerifier) VerifyIdentityTokenInto(tokenString string, dst any) (*IdentityPayload, error) {
+	return v.VerifyIdentityTokenIntoContext(context.Background(), tokenString, dst)
+}
+
+// VerifyIdentityTokenIntoContext 和 VerifyIdentityTokenInto 相同，但是可以指定 context，用于查询 RevocationStore 等外部存储。
+func (v *TokenVerifier) VerifyIdentityTokenIntoContext(ctx context.Context, tokenString string, dst any) (*IdentityPayload, error) {
+	payload, err := v.VerifyIdentityTokenContext(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	data, err := v.decodeClaimsSegment(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return nil, fmt.Errorf("error decoding claims: %w", err)
+	}
+	return payload, nil
+}
+
+// decodeClaimsSegment 返回 Token 中声明部分的 JSON。
+func (v *TokenVerifier) decodeClaimsSegment(tokenString string) ([]byte, error) {
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid token")
+	}
+	data, err := v.parser.DecodeSegment(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("error decoding claims: %w", err)
+	}
+	return data, nil
+}
+
+// extraClaims 返回 Token 中除 known 之外的所有声明组成的 JSON object。如果没有其他声明，则返回空字符串。
+func (v *TokenVerifier) extraClaims(tokenString string, known []string) (string, error) {
+	data, err := v.decodeClaimsSegment(tokenString)
+	if err != nil {
+		return "", err
+	}
+	var extra map[string]json.RawMessage
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return "", fmt.Errorf("error decoding claims: %w", err)
+	}
+	for _, name := range known {
+		delete(extra, name)
+	}
+	if len(extra) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(extra)
+	if err != nil {
+		return "", fmt.Errorf("error encoding claims: %w", err)
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,201 @@
+package combo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// identityClaimsWithExtra adds claims that the SDK does not know about yet.
+type identityClaimsWithExtra struct {
+	identityClaims
+	Region  string `json:"region"`
+	VipRank int    `json:"vip_rank"`
+}
+
+func TestIdentityPayloadMetadata(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now)
+
+	claims := newTestIdentityClaims(now.Add(-time.Minute))
+	claims.ID = "jti_1"
+	claims.NotBefore = jwt.NewNumericDate(now.Add(-time.Minute))
+	payload, err := v.VerifyIdentityToken(signToken(t, claims))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !payload.IssuedAt().Equal(now.Add(-time.Minute)) {
+		t.Errorf("unexpected IssuedAt: %v", payload.IssuedAt())
+	}
+	if !payload.ExpiresAt().Equal(now.Add(59 * time.Minute)) {
+		t.Errorf("unexpected ExpiresAt: %v", payload.ExpiresAt())
+	}
+	if !payload.NotBefore().Equal(now.Add(-time.Minute)) {
+		t.Errorf("unexpected NotBefore: %v", payload.NotBefore())
+	}
+	if payload.TokenId() != "jti_1" {
+		t.Errorf("unexpected TokenId: %s", payload.TokenId())
+	}
+	if payload.Extra() != nil {
+		t.Errorf("expected no extra claims, got %v", payload.Extra())
+	}
+}
+
+func TestIdentityPayloadExtra(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now)
+
+	claims := &identityClaimsWithExtra{
+		identityClaims: *newTestIdentityClaims(now),
+		Region:         "cn",
+		VipRank:        3,
+	}
+	payload, err := v.VerifyIdentityToken(signToken(t, claims))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	extra := payload.Extra()
+	if len(extra) != 2 {
+		t.Fatalf("expected 2 extra claims, got %v", extra)
+	}
+	if string(extra["region"]) != `"cn"` || string(extra["vip_rank"]) != "3" {
+		t.Fatalf("unexpected extra claims: %v", extra)
+	}
+}
+
+func TestAdPayloadMetadataAndExtra(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now)
+
+	claims := jwt.MapClaims{
+		"iss":           string(testEndpoint),
+		"sub":           "combo_123",
+		"aud":           string(testGameId),
+		"exp":           now.Add(time.Hour).Unix(),
+		"iat":           now.Unix(),
+		"jti":           "jti_ad",
+		"scope":         "ads",
+		"placement_id":  "placement_1",
+		"impression_id": "impression_1",
+		"reward_amount": 100,
+	}
+	payload, err := v.VerifyAdToken(signToken(t, claims))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if payload.TokenId() != "jti_ad" || !payload.IssuedAt().Equal(now) {
+		t.Fatalf("unexpected metadata: jti=%s iat=%v", payload.TokenId(), payload.IssuedAt())
+	}
+	if extra := payload.Extra(); len(extra) != 1 || string(extra["reward_amount"]) != "100" {
+		t.Fatalf("unexpected extra claims: %v", extra)
+	}
+}
+
+func TestVerifyIdentityTokenInto(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now)
+
+	tokenString := signToken(t, &identityClaimsWithExtra{
+		identityClaims: *newTestIdentityClaims(now),
+		Region:         "cn",
+		VipRank:        3,
+	})
+	var custom struct {
+		Subject string `json:"sub"`
+		Region  string `json:"region"`
+		VipRank int    `json:"vip_rank"`
+	}
+	payload, err := v.VerifyIdentityTokenInto(tokenString, &custom)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if payload.ComboId != "combo_123" {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+	if custom.Subject != "combo_123" || custom.Region != "cn" || custom.VipRank != 3 {
+		t.Fatalf("unexpected custom claims: %+v", custom)
+	}
+}
+
+func TestVerifyIdentityTokenIntoInvalidToken(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now)
+
+	claims := newTestIdentityClaims(now)
+	claims.Scope = "ads"
+	var custom map[string]json.RawMessage
+	if _, err := v.VerifyIdentityTokenInto(signToken(t, claims), &custom); err == nil {
+		t.Fatal("expected error for invalid scope")
+	}
+	if custom != nil {
+		t.Fatal("dst should not be modified when verification fails")
+	}
+}
+
+func TestTokenCacheClonesExtra(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now, WithTokenCache(10))
+
+	tokenString := signToken(t, &identityClaimsWithExtra{
+		identityClaims: *newTestIdentityClaims(now),
+		Region:         "cn",
+	})
+	first, err := v.VerifyIdentityToken(tokenString)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	delete(first.Extra(), "region")
+
+	second, err := v.VerifyIdentityToken(tokenString)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(second.Extra()["region"]) != `"cn"` {
+		t.Fatalf("expected cached extra claims to be unaffected, got %v", second.Extra())
+	}
+}
+
+func TestPayloadsAreComparable(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now)
+
+	tokenString := signToken(t, &identityClaimsWithExtra{
+		identityClaims: *newTestIdentityClaims(now),
+		Region:         "cn",
+	})
+	first, err := v.VerifyIdentityToken(tokenString)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := v.VerifyIdentityToken(tokenString)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *first != *second {
+		t.Fatalf("expected payloads of the same token to be equal: %+v, %+v", first, second)
+	}
+	seen := map[AdPayload]bool{{ComboId: "combo_123"}: true}
+	if !seen[AdPayload{ComboId: "combo_123"}] {
+		t.Fatal("expected AdPayload to be usable as a map key")
+	}
+}
+
+func TestVerifyIdentityTokenIntoContext(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now, WithRevocationStore(failingRevocationStore{}))
+
+	var custom map[string]json.RawMessage
+	_, err := v.VerifyIdentityTokenIntoContext(context.Background(), signToken(t, newTestIdentityClaims(now)), &custom)
+	var storeErr *TokenStoreError
+	if !errors.As(err, &storeErr) {
+		t.Fatalf("expected *TokenStoreError, got %v", err)
+	}
+	if custom != nil {
+		t.Fatal("dst should not be modified when verification fails")
+	}
+}
@@ -0,0 +1,296 @@
+package combo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrTokenRevoked 表示 Identity Token 的签名和声明都是有效的，但是已经被游戏侧吊销了。
+var ErrTokenRevoked = errors.New("token is revoked")
+
+// WithRevocationStore 用于开启 Identity Token 的吊销检查。
+//
+// 开启后，VerifyIdentityToken 会在 Token 通过其他验证之后查询 store，如果 Token 已被吊销，则返回 ErrTokenRevoked。
+// 如果查询 store 出错，则 VerifyIdentityToken 也会返回 error。
+func WithRevocationStore(store RevocationStore) VerifierOption {
+	return func(v *TokenVerifier) {
+		v.revocationStore = store
+	}
+}
+
+// RevocationCheck 包含了检查 Identity Token 是否被吊销所需的信息。
+type RevocationCheck struct {
+	// Token 的唯一 ID (jti)。如果 Token 不包含 jti 则为空字符串。
+	TokenId string
+
+	// Token 所属用户的 ComboId。
+	ComboId string
+
+	// Token 所属设备的 DeviceId。
+	DeviceId string
+
+	// Token 的签发时间 (iat)。如果 Token 不包含 iat 则为零值。
+	IssuedAt time.Time
+}
+
+// RevocationStore 是一个用于存储 Identity Token 吊销记录的接口。
+//
+// Combo SDK 内置了 Redis 和 Memory 两种实现，可分别通过 NewMemoryRevocationStore() 和 NewRedisRevocationStore() 创建。
+//
+// 游戏侧也可以选择自行实现 RevocationStore 接口。
+type RevocationStore interface {
+	// RevokeTokenId 吊销 jti 为 tokenId 的 Token。
+	// 吊销记录在 ttl 之后过期，ttl 应当不短于 Token 的剩余有效期。ttl 为 0 表示永不过期。
+	RevokeTokenId(ctx context.Context, tokenId string, ttl time.Duration) error
+
+	// RevokeComboId 吊销 comboId 对应用户在 cutoff 之前签发的所有 Token，常用于强制用户下线。
+	// Token 的签发时间 (iat) 只精确到秒，因此比较时 cutoff 也会被截断到秒，和 cutoff 在同一秒内签发的 Token 不会被吊销。
+	// 吊销记录在 ttl 之后过期，ttl 应当不短于 Token 的最长有效期。ttl 为 0 表示永不过期。
+	RevokeComboId(ctx context.Context, comboId string, cutoff time.Time, ttl time.Duration) error
+
+	// RevokeDeviceId 吊销 deviceId 对应设备的所有 Token，常用于封禁设备。
+	// 吊销记录在 ttl 之后过期。ttl 为 0 表示永不过期。
+	RevokeDeviceId(ctx context.Context, deviceId string, ttl time.Duration) error
+
+	// IsRevoked 检查 Token 是否已被吊销。
+	IsRevoked(ctx context.Context, check RevocationCheck) (bool, error)
+}
+
+// NewMemoryRevocationStore 创建一个基于 Memory 的 RevocationStore 实现。
+//
+// 注意：该实现仅用于开发调试，不适合生产环境。
+//
+// 数据仅在内存中存储，重启服务后数据会丢失，并且无法在多个游戏服务实例之间共享。过期的数据会被定期清理。
+func NewMemoryRevocationStore(options ...MemoryStoreOption) RevocationStore {
+	return &memoryRevocationStore{
+		clock:     newMemoryStoreOptions(options).clock,
+		tokenIds:  make(map[string]time.Time),
+		comboIds:  make(map[string]memoryRevocationCutoff),
+		deviceIds: make(map[string]time.Time),
+	}
+}
+
+// NewRedisRevocationStore 创建一个基于 Redis 的 RevocationStore 实现。
+//
+// 数据会存储在 Redis 中，可以在多个游戏服务实例之间共享，并且到期自动清理。推荐生产环境使用。
+func NewRedisRevocationStore(cfg RedisRevocationStoreConfig) RevocationStore {
+	if cfg.Client == nil {
+		panic("missing required cfg.Client")
+	}
+	return &redisRevocationStore{
+		client: cfg.Client,
+		prefix: cfg.Prefix,
+	}
+}
+
+// RedisRevocationStoreConfig 包含了创建基于 Redis 的 RevocationStore 时所必需的配置项。
+type RedisRevocationStoreConfig struct {
+	Client redis.Cmdable // Redis 客户端。这里不假设 Redis 的运维部署方式。可以是 redis.Client 或者 redis.ClusterClient，由游戏侧自行创建和配置。
+	Prefix string        // Key 的前缀，如果不指定，则默认为空字符串。
+}
+
+// checkRevocation 检查 Identity Token 是否已被吊销。
+func (v *TokenVerifier) checkRevocation(ctx context.Context, claims *identityClaims) error {
+	if v.revocationStore == nil {
+		return nil
+	}
+	check := RevocationCheck{
+		TokenId:  claims.ID,
+		ComboId:  claims.Subject,
+		DeviceId: claims.DeviceId,
+	}
+	if claims.IssuedAt != nil {
+		check.IssuedAt = claims.IssuedAt.Time
+	}
+	revoked, err := v.revocationStore.IsRevoked(ctx, check)
+	if err != nil {
+		return &TokenStoreError{Op: "checking token revocation", Err: err}
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// revocationExpiry 将 ttl 转换为吊销记录的过期时间，ttl 为 0 表示永不过期，对应零值。
+func revocationExpiry(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+// revocationExpired 判断吊销记录是否已过期，零值表示永不过期。
+func revocationExpired(now, expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
+
+// memoryRevocationStoreSweepInterval 是 memoryRevocationStore 清理过期数据的最小时间间隔。
+const memoryRevocationStoreSweepInterval = time.Minute
+
+type memoryRevocationCutoff struct {
+	cutoff    int64 // Unix timestamp in seconds
+	expiresAt time.Time
+}
+
+type memoryRevocationStore struct {
+	mu        sync.Mutex
+	clock     Clock
+	tokenIds  map[string]time.Time
+	comboIds  map[string]memoryRevocationCutoff
+	deviceIds map[string]time.Time
+	lastSweep time.Time
+}
+
+// sweep 清理过期的吊销记录，两次清理之间至少间隔 memoryRevocationStoreSweepInterval。调用方必须持有锁。
+func (s *memoryRevocationStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memoryRevocationStoreSweepInterval {
+		return
+	}
+	for k, expiresAt := range s.tokenIds {
+		if revocationExpired(now, expiresAt) {
+			delete(s.tokenIds, k)
+		}
+	}
+	for k, c := range s.comboIds {
+		if revocationExpired(now, c.expiresAt) {
+			delete(s.comboIds, k)
+		}
+	}
+	for k, expiresAt := range s.deviceIds {
+		if revocationExpired(now, expiresAt) {
+			delete(s.deviceIds, k)
+		}
+	}
+	s.lastSweep = now
+}
+
+// RevokeTokenId implements RevocationStore.
+func (s *memoryRevocationStore) RevokeTokenId(ctx context.Context, tokenId string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	s.sweep(now)
+	s.tokenIds[tokenId] = revocationExpiry(now, ttl)
+	return nil
+}
+
+// RevokeComboId implements RevocationStore.
+func (s *memoryRevocationStore) RevokeComboId(ctx context.Context, comboId string, cutoff time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	s.sweep(now)
+	// 和 Redis 实现一致，cutoff 只精确到秒。
+	s.comboIds[comboId] = memoryRevocationCutoff{cutoff: cutoff.Unix(), expiresAt: revocationExpiry(now, ttl)}
+	return nil
+}
+
+// RevokeDeviceId implements RevocationStore.
+func (s *memoryRevocationStore) RevokeDeviceId(ctx context.Context, deviceId string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	s.sweep(now)
+	s.deviceIds[deviceId] = revocationExpiry(now, ttl)
+	return nil
+}
+
+// IsRevoked implements RevocationStore.
+func (s *memoryRevocationStore) IsRevoked(ctx context.Context, check RevocationCheck) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	if check.TokenId != "" {
+		if exp, ok := s.tokenIds[check.TokenId]; ok && !revocationExpired(now, exp) {
+			return true, nil
+		}
+	}
+	if check.DeviceId != "" {
+		if exp, ok := s.deviceIds[check.DeviceId]; ok && !revocationExpired(now, exp) {
+			return true, nil
+		}
+	}
+	if c, ok := s.comboIds[check.ComboId]; ok && !revocationExpired(now, c.expiresAt) {
+		// 缺少签发时间的 Token 无法判断是否在 cutoff 之前签发，保守起见视为已吊销。
+		if check.IssuedAt.IsZero() || check.IssuedAt.Unix() < c.cutoff {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type redisRevocationStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+func (s *redisRevocationStore) tokenIdKey(tokenId string) string {
+	return s.prefix + "jti:" + tokenId
+}
+
+func (s *redisRevocationStore) comboIdKey(comboId string) string {
+	return s.prefix + "combo_id:" + comboId
+}
+
+func (s *redisRevocationStore) deviceIdKey(deviceId string) string {
+	return s.prefix + "device_id:" + deviceId
+}
+
+// RevokeTokenId implements RevocationStore.
+func (s *redisRevocationStore) RevokeTokenId(ctx context.Context, tokenId string, ttl time.Duration) error {
+	return s.client.Set(ctx, s.tokenIdKey(tokenId), 1, ttl).Err()
+}
+
+// RevokeComboId implements RevocationStore.
+func (s *redisRevocationStore) RevokeComboId(ctx context.Context, comboId string, cutoff time.Time, ttl time.Duration) error {
+	return s.client.Set(ctx, s.comboIdKey(comboId), cutoff.Unix(), ttl).Err()
+}
+
+// RevokeDeviceId implements RevocationStore.
+func (s *redisRevocationStore) RevokeDeviceId(ctx context.Context, deviceId string, ttl time.Duration) error {
+	return s.client.Set(ctx, s.deviceIdKey(deviceId), 1, ttl).Err()
+}
+
+// IsRevoked implements RevocationStore.
+func (s *redisRevocationStore) IsRevoked(ctx context.Context, check RevocationCheck) (bool, error) {
+	// 这里使用 pipeline 而不是 MGET，因为在 Redis Cluster 中这些 key 可能位于不同的 slot。
+	var tokenIdCmd, deviceIdCmd *redis.IntCmd
+	var comboIdCmd *redis.StringCmd
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		if check.TokenId != "" {
+			tokenIdCmd = pipe.Exists(ctx, s.tokenIdKey(check.TokenId))
+		}
+		if check.DeviceId != "" {
+			deviceIdCmd = pipe.Exists(ctx, s.deviceIdKey(check.DeviceId))
+		}
+		comboIdCmd = pipe.Get(ctx, s.comboIdKey(check.ComboId))
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return false, err
+	}
+	if tokenIdCmd != nil && tokenIdCmd.Val() > 0 {
+		return true, nil
+	}
+	if deviceIdCmd != nil && deviceIdCmd.Val() > 0 {
+		return true, nil
+	}
+	cutoffStr, err := comboIdCmd.Result()
+	if err == redis.Nil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	cutoff, err := strconv.ParseInt(cutoffStr, 10, 64)
+	if err != nil {
+		return false, err
+	}
+	// 缺少签发时间的 Token 无法判断是否在 cutoff 之前签发，保守起见视为已吊销。
+	return check.IssuedAt.IsZero() || check.IssuedAt.Unix() < cutoff, nil
+}
@@ -0,0 +1,205 @@
+package combo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func testRevocationStore(t *testing.T, store RevocationStore) {
+	t.Helper()
+	ctx := context.Background()
+	issuedAt := time.Now().Add(-time.Hour)
+
+	check := RevocationCheck{TokenId: "jti_1", ComboId: "combo_1", DeviceId: "device_1", IssuedAt: issuedAt}
+	if revoked, err := store.IsRevoked(ctx, check); err != nil || revoked {
+		t.Fatalf("expected token not to be revoked, got revoked=%v, err=%v", revoked, err)
+	}
+
+	if err := store.RevokeTokenId(ctx, "jti_1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := store.IsRevoked(ctx, check); !revoked {
+		t.Fatal("expected token to be revoked by jti")
+	}
+	if revoked, _ := store.IsRevoked(ctx, RevocationCheck{TokenId: "jti_2", ComboId: "combo_1", IssuedAt: issuedAt}); revoked {
+		t.Fatal("expected other jti not to be revoked")
+	}
+
+	if err := store.RevokeDeviceId(ctx, "device_2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := store.IsRevoked(ctx, RevocationCheck{ComboId: "combo_2", DeviceId: "device_2", IssuedAt: issuedAt}); !revoked {
+		t.Fatal("expected token to be revoked by device id")
+	}
+
+	if err := store.RevokeComboId(ctx, "combo_3", issuedAt.Add(time.Minute), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := store.IsRevoked(ctx, RevocationCheck{ComboId: "combo_3", IssuedAt: issuedAt}); !revoked {
+		t.Fatal("expected token issued before cutoff to be revoked")
+	}
+	if revoked, _ := store.IsRevoked(ctx, RevocationCheck{ComboId: "combo_3", IssuedAt: issuedAt.Add(2 * time.Minute)}); revoked {
+		t.Fatal("expected token issued after cutoff not to be revoked")
+	}
+	if revoked, _ := store.IsRevoked(ctx, RevocationCheck{ComboId: "combo_3"}); !revoked {
+		t.Fatal("expected token without iat to be revoked")
+	}
+}
+
+func testRevocationCutoffGranularity(t *testing.T, store RevocationStore) {
+	t.Helper()
+	ctx := context.Background()
+	issuedAt := time.Now().Truncate(time.Second)
+
+	// The cutoff is later than iat within the same second, but iat only has second precision.
+	if err := store.RevokeComboId(ctx, "combo_1", issuedAt.Add(500*time.Millisecond), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := store.IsRevoked(ctx, RevocationCheck{ComboId: "combo_1", IssuedAt: issuedAt}); revoked {
+		t.Fatal("expected token issued in the same second as cutoff not to be revoked")
+	}
+	if revoked, _ := store.IsRevoked(ctx, RevocationCheck{ComboId: "combo_1", IssuedAt: issuedAt.Add(-time.Second)}); !revoked {
+		t.Fatal("expected token issued in the previous second to be revoked")
+	}
+}
+
+func TestRevocationCutoffGranularity(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testRevocationCutoffGranularity(t, NewMemoryRevocationStore())
+	})
+	t.Run("redis", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		testRevocationCutoffGranularity(t, NewRedisRevocationStore(RedisRevocationStoreConfig{Client: client}))
+	})
+}
+
+func TestMemoryRevocationStoreSweep(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryRevocationStore(WithMemoryStoreClock(ClockFunc(func() time.Time { return now }))).(*memoryRevocationStore)
+	ctx := context.Background()
+
+	_ = store.RevokeTokenId(ctx, "jti_1", time.Minute)
+	_ = store.RevokeComboId(ctx, "combo_1", now, time.Minute)
+	_ = store.RevokeDeviceId(ctx, "device_1", time.Minute)
+	_ = store.RevokeDeviceId(ctx, "device_2", 0)
+
+	now = now.Add(2 * time.Minute)
+	_ = store.RevokeTokenId(ctx, "jti_2", time.Minute)
+	if len(store.tokenIds) != 1 || len(store.comboIds) != 0 || len(store.deviceIds) != 1 {
+		t.Fatalf("expected expired entries to be swept, got tokenIds=%v comboIds=%v deviceIds=%v", store.tokenIds, store.comboIds, store.deviceIds)
+	}
+}
+
+func TestMemoryRevocationStore(t *testing.T) {
+	testRevocationStore(t, NewMemoryRevocationStore())
+}
+
+func TestMemoryRevocationStoreExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryRevocationStore(WithMemoryStoreClock(ClockFunc(func() time.Time { return now })))
+	ctx := context.Background()
+
+	_ = store.RevokeTokenId(ctx, "jti_1", time.Minute)
+	if revoked, _ := store.IsRevoked(ctx, RevocationCheck{TokenId: "jti_1"}); !revoked {
+		t.Fatal("expected token to be revoked")
+	}
+	now = now.Add(time.Minute)
+	if revoked, _ := store.IsRevoked(ctx, RevocationCheck{TokenId: "jti_1"}); revoked {
+		t.Fatal("expected revocation to expire")
+	}
+}
+
+func TestNewRedisRevocationStorePanicsWithoutClient(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected panic for missing client")
+		}
+	}()
+	NewRedisRevocationStore(RedisRevocationStoreConfig{})
+}
+
+func TestRedisRevocationStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := NewRedisRevocationStore(RedisRevocationStoreConfig{
+		Client: client,
+		Prefix: "revoked:",
+	})
+	testRevocationStore(t, store)
+
+	if !mr.Exists("revoked:jti:jti_1") {
+		t.Fatal("expected key to be stored with prefix")
+	}
+	mr.FastForward(2 * time.Hour)
+	if revoked, _ := store.IsRevoked(context.Background(), RevocationCheck{TokenId: "jti_1"}); revoked {
+		t.Fatal("expected revocation to expire")
+	}
+}
+
+func TestVerifierWithRevocationStore(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryRevocationStore()
+	v := newTestVerifierWithOptions(t, now, WithRevocationStore(store))
+	ctx := context.Background()
+
+	claims := newTestIdentityClaims(now.Add(-time.Minute))
+	claims.ID = "jti_1"
+	claims.DeviceId = "device_1"
+	tokenString := signToken(t, claims)
+
+	if _, err := v.VerifyIdentityTokenContext(ctx, tokenString); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_ = store.RevokeComboId(ctx, claims.Subject, now, 0)
+	if _, err := v.VerifyIdentityToken(tokenString); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected ErrTokenRevoked, got %v", err)
+	}
+
+	// Tokens issued after the cutoff are still accepted.
+	_ = store.RevokeComboId(ctx, claims.Subject, now.Add(-30*time.Second), 0)
+	if _, err := v.VerifyIdentityToken(signToken(t, newTestIdentityClaims(now))); err != nil {
+		t.Fatalf("expected token issued after cutoff to be accepted: %v", err)
+	}
+	noIat := newTestIdentityClaims(now)
+	noIat.IssuedAt = nil
+	if _, err := v.VerifyIdentityToken(signToken(t, noIat)); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected token without iat to be revoked, got %v", err)
+	}
+
+	_ = store.RevokeDeviceId(ctx, "device_1", time.Hour)
+	deviceClaims := newTestIdentityClaims(now)
+	deviceClaims.Subject = "combo_456"
+	deviceClaims.DeviceId = "device_1"
+	if _, err := v.VerifyIdentityToken(signToken(t, deviceClaims)); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected token to be revoked by device id, got %v", err)
+	}
+}
+
+type failingRevocationStore struct {
+	RevocationStore
+}
+
+func (failingRevocationStore) IsRevoked(ctx context.Context, check RevocationCheck) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+func TestVerifierRevocationStoreError(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now, WithRevocationStore(failingRevocationStore{}))
+
+	_, err := v.VerifyIdentityToken(signToken(t, newTestIdentityClaims(now)))
+	if err == nil || errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected store error, got %v", err)
+	}
+	var storeErr *TokenStoreError
+	if !errors.As(err, &storeErr) || storeErr.Op != "checking token revocation" {
+		t.Fatalf("expected *TokenStoreError, got %T: %v", err, err)
+	}
+}
@@ -0,0 +1,54 @@
+package combo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrTokenAlreadyUsed 表示 Identity Token 已经被使用过。仅在通过 WithSingleUse 开启一次性使用模式时出现。
+var ErrTokenAlreadyUsed = errors.New("token is already used")
+
+// WithSingleUse 用于开启 Identity Token 的一次性使用模式。
+//
+// 开启后，每个通过验证的 Identity Token 都会被记录在 store 中，直到 Token 过期。
+// 该校验在其他所有校验通过之后才进行，未通过验证的 Token 不会被记录，可以在问题解决后重试。
+// 在此期间，再次验证同一个 Token 会返回 ErrTokenAlreadyUsed。
+// Token 包含 jti 时以 jti 作为记录的 key，否则以 Token 的 SHA-256 哈希作为 key。
+//
+// store 可以和 WithReplayStore 共用同一个 ReplayStore，两者的 key 不会冲突。
+func WithSingleUse(store ReplayStore) VerifierOption {
+	return func(v *TokenVerifier) {
+		v.singleUseStore = store
+	}
+}
+
+// checkSingleUse 将通过验证的 Identity Token 记录到 singleUseStore 中。
+// 如果 Token 已经被使用过，则返回 ErrTokenAlreadyUsed。
+func (v *TokenVerifier) checkSingleUse(ctx context.Context, tokenString string, claims *identityClaims) error {
+	if v.singleUseStore == nil {
+		return nil
+	}
+	var key string
+	if claims.ID != "" {
+		key = "identity_token:jti:" + claims.ID
+	} else {
+		sum := sha256.Sum256([]byte(tokenString))
+		key = "identity_token:sha256:" + hex.EncodeToString(sum[:])
+	}
+	// Token 过期后会被 exp 校验拒绝，所以无需继续记录。
+	ttl := claims.ExpiresAt.Add(v.leeway).Sub(v.clock.Now())
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	added, err := v.singleUseStore.Add(ctx, key, ttl)
+	if err != nil {
+		return &TokenStoreError{Op: "recording token use", Err: err}
+	}
+	if !added {
+		return ErrTokenAlreadyUsed
+	}
+	return nil
+}
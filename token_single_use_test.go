@@ -0,0 +1,122 @@
+package combo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestVerifierWithSingleUse(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now, WithSingleUse(NewMemoryReplayStore()))
+
+	claims := newTestIdentityClaims(now)
+	claims.ID = "jti_1"
+	tokenString := signToken(t, claims)
+
+	if _, err := v.VerifyIdentityToken(tokenString); err != nil {
+		t.Fatalf("unexpected error on first use: %v", err)
+	}
+	if _, err := v.VerifyIdentityToken(tokenString); !errors.Is(err, ErrTokenAlreadyUsed) {
+		t.Fatalf("expected ErrTokenAlreadyUsed, got %v", err)
+	}
+
+	// A different token with the same jti is treated as the same ticket.
+	claims.ExternalId = "other"
+	if _, err := v.VerifyIdentityToken(signToken(t, claims)); !errors.Is(err, ErrTokenAlreadyUsed) {
+		t.Fatalf("expected ErrTokenAlreadyUsed for reused jti, got %v", err)
+	}
+}
+
+func TestVerifierWithSingleUseWithoutJti(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now, WithSingleUse(NewMemoryReplayStore()))
+
+	tokenString := signToken(t, newTestIdentityClaims(now))
+	if _, err := v.VerifyIdentityToken(tokenString); err != nil {
+		t.Fatalf("unexpected error on first use: %v", err)
+	}
+	if _, err := v.VerifyIdentityToken(tokenString); !errors.Is(err, ErrTokenAlreadyUsed) {
+		t.Fatalf("expected ErrTokenAlreadyUsed, got %v", err)
+	}
+
+	other := newTestIdentityClaims(now)
+	other.Subject = "combo_456"
+	if _, err := v.VerifyIdentityToken(signToken(t, other)); err != nil {
+		t.Fatalf("unexpected error for a different token: %v", err)
+	}
+}
+
+func TestVerifierWithSingleUseSkipsInvalidTokens(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryReplayStore()
+	v := newTestVerifierWithOptions(t, now, WithSingleUse(store), WithAllowedIdPs(IdP_Seayoo))
+
+	tokenString := signToken(t, newTestIdentityClaims(now))
+	for i := 0; i < 2; i++ {
+		if _, err := v.VerifyIdentityToken(tokenString); !errors.Is(err, ErrIdPNotAllowed) {
+			t.Fatalf("expected ErrIdPNotAllowed, got %v", err)
+		}
+	}
+}
+
+func TestVerifierWithSingleUseRedisTTL(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := NewRedisReplayStore(RedisReplayStoreConfig{Client: client})
+
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now, WithSingleUse(store))
+	claims := newTestIdentityClaims(now)
+	claims.ID = "jti_1"
+	if _, err := v.VerifyIdentityTokenContext(context.Background(), signToken(t, claims)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key := "identity_token:jti:jti_1"
+	if !mr.Exists(key) {
+		t.Fatalf("expected %s to be recorded", key)
+	}
+	if ttl := mr.TTL(key); ttl != time.Hour {
+		t.Fatalf("expected ttl to match token expiry, got %v", ttl)
+	}
+}
+
+type flakySessionStore struct {
+	SessionStore
+	fail bool
+}
+
+func (s *flakySessionStore) Claim(ctx context.Context, session Session, ttl time.Duration) (bool, *Session, error) {
+	if s.fail {
+		return false, nil, errors.New("store unavailable")
+	}
+	return s.SessionStore.Claim(ctx, session, ttl)
+}
+
+func TestVerifierWithSingleUseAfterSessionFailure(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	sessions := &flakySessionStore{SessionStore: NewMemorySessionStore(), fail: true}
+	registry := NewSessionRegistry(SessionRegistryConfig{Store: sessions})
+	v := newTestVerifierWithOptions(t, now, WithSingleUse(NewMemoryReplayStore()), WithSessionRegistry(registry))
+
+	claims := newTestIdentityClaims(now)
+	claims.ID = "jti_1"
+	claims.DeviceId = "device_1"
+	tokenString := signToken(t, claims)
+
+	if _, err := v.VerifyIdentityToken(tokenString); err == nil {
+		t.Fatal("expected session store error")
+	}
+	sessions.fail = false
+	if _, err := v.VerifyIdentityToken(tokenString); err != nil {
+		t.Fatalf("expected token to be accepted after the session store recovered, got %v", err)
+	}
+	if _, err := v.VerifyIdentityToken(tokenString); !errors.Is(err, ErrTokenAlreadyUsed) {
+		t.Fatalf("expected ErrTokenAlreadyUsed, got %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package combo
+
+// TokenStoreError 表示 Token 的签名和声明都是有效的，但是在访问 Store 时出错，例如 Redis 不可用。
+//
+// 这类错误只是暂时无法完成验证，并不意味着 Token 无效。游戏侧通常应当返回 503 之类的服务端错误，
+// 而不是要求用户重新登录，以免 Store 故障时所有用户都被登出。可使用 errors.As 识别这类错误。
+//
+// 涉及的 Store 包括 WithRevocationStore、WithSingleUse、WithSessionRegistry 指定的 Store，以及 SessionIssuer 的 Store。
+type TokenStoreError struct {
+	// 出错的操作，例如 "checking token revocation"。
+	Op string
+
+	// Store 返回的原始错误。
+	Err error
+}
+
+// Error implements error.
+func (e *TokenStoreError) Error() string {
+	return "error " + e.Op + ": " + e.Err.Error()
+}
+
+// Unwrap 返回 Store 返回的原始错误。
+func (e *TokenStoreError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,64 @@
+package combo
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// NewSigningTransport 创建一个会对每个 HTTP 请求进行签名的 http.RoundTripper。
+//
+// 当游戏侧需要通过 OpenAPI 生成的 client 或其他只接受 *http.Client 的 HTTP 库来调用 Combo Server API 时，
+// 可以将此 RoundTripper 作为 http.Client 的 Transport 使用，示例如下：
+//
+//	transport, err := combo.NewSigningTransport(cfg, http.DefaultTransport)
+//	if err != nil {
+//	    panic(err)
+//	}
+//	httpClient := &http.Client{Transport: transport}
+//
+// 每个请求都会被设置 User-Agent 和 Authorization。调用方传入的 *http.Request 不会被修改。
+//
+// 如果 base 为 nil，则默认使用 http.DefaultTransport。
+func NewSigningTransport(cfg Config, base http.RoundTripper) (http.RoundTripper, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &signingTransport{
+		base:      base,
+		signer:    newHttpSigner(cfg),
+		userAgent: userAgent(cfg.GameId),
+	}, nil
+}
+
+type signingTransport struct {
+	base      http.RoundTripper
+	signer    httpSigner
+	userAgent string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *signingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// http.RoundTripper 不应修改传入的请求，所以这里对 r 进行了深拷贝，后续只修改拷贝后的请求。
+	req := r.Clone(r.Context())
+	if r.Body != nil && r.Body != http.NoBody {
+		// 按照 http.RoundTripper 的约定，原始请求的 body 总是需要被关闭。
+		defer r.Body.Close()
+		if r.GetBody != nil {
+			// 优先通过 GetBody 获取一份新的 body 用于签名，原始请求的 body 保持不变。
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get request body: %w", err)
+			}
+			req.Body = body
+		}
+	}
+	req.Header.Set("User-Agent", t.userAgent)
+	// SignHttp 计算签名时会将 body 读取到内存中，并替换 req.Body 和 req.GetBody，以便重定向和重试时可以重放 body。
+	if err := t.signer.SignHttp(req, t.signer.now()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,162 @@
+package combo
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestNewSigningTransportInvalidConfig(t *testing.T) {
+	_, err := NewSigningTransport(Config{}, nil)
+	if err == nil {
+		t.Fatal("expected error for invalid config")
+	}
+}
+
+func TestSigningTransportSignsRequest(t *testing.T) {
+	cfg := newTestConfig()
+	signer := &httpSigner{game: cfg.GameId, signingKey: cfg.SecretKey}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := signer.AuthHttp(r, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasPrefix(r.UserAgent(), SdkName) {
+			http.Error(w, "unexpected user agent: "+r.UserAgent(), http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	transport, err := NewSigningTransport(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Post(server.URL+"/v1/server/test-api", "application/json", strings.NewReader(`{"key":"value"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if string(body) != `{"key":"value"}` {
+		t.Fatalf("unexpected echoed body: %s", body)
+	}
+}
+
+func TestSigningTransportDoesNotMutateRequest(t *testing.T) {
+	var got *http.Request
+	transport, err := NewSigningTransport(newTestConfig(), roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = r
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"key":"value"}`)
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/v1/server/test-api", bytes.NewReader(body))
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Header.Get(authorizationHeader) != "" {
+		t.Fatal("original request should not be signed")
+	}
+	if req.Header.Get("User-Agent") != "" {
+		t.Fatal("original request should not have user agent set")
+	}
+	if got.Header.Get(authorizationHeader) == "" {
+		t.Fatal("outgoing request should be signed")
+	}
+	sent, _ := io.ReadAll(got.Body)
+	if !bytes.Equal(sent, body) {
+		t.Fatalf("expected outgoing body %s, got %s", body, sent)
+	}
+	// GetBody should still produce the original body for replays.
+	replay, _ := req.GetBody()
+	replayed, _ := io.ReadAll(replay)
+	if !bytes.Equal(replayed, body) {
+		t.Fatalf("expected replayed body %s, got %s", body, replayed)
+	}
+}
+
+func TestSigningTransportNonRewindableBody(t *testing.T) {
+	var got *http.Request
+	transport, err := NewSigningTransport(newTestConfig(), roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = r
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"key":"value"}`)
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/v1/server/test-api", io.NopCloser(bytes.NewReader(body)))
+	if req.GetBody != nil {
+		t.Fatal("test setup: expected request without GetBody")
+	}
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sent, _ := io.ReadAll(got.Body)
+	if !bytes.Equal(sent, body) {
+		t.Fatalf("expected outgoing body %s, got %s", body, sent)
+	}
+	// The buffered body can be replayed on redirects and retries.
+	if got.GetBody == nil {
+		t.Fatal("expected GetBody to be set on the outgoing request")
+	}
+	for i := 0; i < 2; i++ {
+		rc, err := got.GetBody()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if replayed, _ := io.ReadAll(rc); !bytes.Equal(replayed, body) {
+			t.Fatalf("expected replayed body %s, got %s", body, replayed)
+		}
+	}
+	signer := &httpSigner{game: testGameId, signingKey: SecretKey(testSecretKey)}
+	got.Body = io.NopCloser(bytes.NewReader(sent))
+	if err := signer.AuthHttp(got, time.Now()); err != nil {
+		t.Fatalf("AuthHttp failed: %v", err)
+	}
+}
+
+func TestSigningTransportNilBody(t *testing.T) {
+	var got *http.Request
+	transport, err := NewSigningTransport(newTestConfig(), roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = r
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/v1/server/test-api", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Body != nil {
+		t.Fatal("outgoing request should not have a body")
+	}
+	signer := &httpSigner{game: testGameId, signingKey: SecretKey(testSecretKey)}
+	if err := signer.AuthHttp(got, time.Now()); err != nil {
+		t.Fatalf("AuthHttp failed: %v", err)
+	}
+}
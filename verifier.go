@@ -1,13 +1,13 @@
 package combo
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/sha256"
-	"encoding/base64"
+	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/seayoo-io/combo-sdk-go/internal/aesgcm"
 )
 
 const (
@@ -18,23 +18,51 @@ const (
 // TokenVerifier 用于验证世游服务端颁发的 Token。
 type TokenVerifier struct {
 	parser *jwt.Parser
-	key    SecretKey
+	game   GameId
+	keys   keyring
+	clock  Clock
+
+	leeway           time.Duration
+	maxAge           time.Duration
+	allowedIdPs      map[IdP]bool
+	allowedDistros   map[string]bool
+	allowedVariants  map[string]bool
+	identityPolicies []func(*IdentityPayload) error
+	revocationStore  RevocationStore
+	singleUseStore   ReplayStore
+	sessionRegistry  *SessionRegistry
+	cache            *tokenCache
 }
 
 // NewTokenVerifier 创建一个新的 TokenVerifier。
-func NewTokenVerifier(cfg Config) (*TokenVerifier, error) {
+//
+// 默认的验证策略是：签名算法为 HS256，必须包含 exp，iss 为 Config.Endpoint，aud 为 Config.GameId。
+// 可以通过 VerifierOption 指定额外的验证策略。
+func NewTokenVerifier(cfg Config, options ...VerifierOption) (*TokenVerifier, error) {
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
-	return &TokenVerifier{
-		parser: jwt.NewParser(
-			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
-			jwt.WithExpirationRequired(),
-			jwt.WithIssuer(string(cfg.Endpoint)),
-			jwt.WithAudience(string(cfg.GameId)),
-		),
-		key: cfg.SecretKey,
-	}, nil
+	v := &TokenVerifier{
+		game:  cfg.GameId,
+		keys:  newKeyring(cfg),
+		clock: cfg.Clock,
+	}
+	for _, option := range options {
+		option(v)
+	}
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(string(cfg.Endpoint)),
+		jwt.WithAudience(string(cfg.GameId)),
+		jwt.WithTimeFunc(cfg.Clock.Now),
+		jwt.WithLeeway(v.leeway),
+	}
+	if v.maxAge > 0 {
+		parserOptions = append(parserOptions, jwt.WithIssuedAt())
+	}
+	v.parser = jwt.NewParser(parserOptions...)
+	return v, nil
 }
 
 // IdentityPayload 包含了用户的身份信息。
@@ -64,7 +92,7 @@ type IdentityPayload struct {
 	// WeixinSessionKey 是用户在微信小游戏登录时，从微信服务端获得的会话密钥 session_key。
 	// 该字段在 Identity Token 中以 AES-256-GCM 加密存储，SDK 会自动解密。
 	//
-	// 注意：WeixinSessionKey 只在 IdP 为 Idp_MinigameWeixin 时才会有值。
+	// 注意：WeixinSessionKey 只在 IdP 为 IdP_MinigameWeixin 时才会有值。
 	WeixinSessionKey string
 
 	// DeviceId 是用户在登录时使用的设备的唯一 ID。
@@ -93,6 +121,11 @@ type IdentityPayload struct {
 	// RegTime 是用户 ID（Combo ID）注册时间。
 	// Unix timestamp in seconds。
 	RegTime int64
+
+	// Token 的签发时间、过期时间、jti，以及 SDK 尚不认识的声明，可通过 IssuedAt()、ExpiresAt()、TokenId()、Extra() 等方法获取。
+	//
+	// 注意：这些信息不会被 json.Marshal 序列化。如果需要序列化，请将方法的返回值显式地放入游戏侧自己的结构体中。
+	tokenMetadata
 }
 
 // AdPayload 包含了激励广告的播放信息。
@@ -106,6 +139,11 @@ type AdPayload struct {
 
 	// ImpressionId 是世游服务端创建的，标识单次广告播放的唯一 ID。
 	ImpressionId string
+
+	// Token 的签发时间、过期时间、jti，以及 SDK 尚不认识的声明，可通过 IssuedAt()、ExpiresAt()、TokenId()、Extra() 等方法获取。
+	//
+	// 注意：这些信息不会被 json.Marshal 序列化。如果需要序列化，请将方法的返回值显式地放入游戏侧自己的结构体中。
+	tokenMetadata
 }
 
 type identityClaims struct {
@@ -133,23 +171,57 @@ type adClaims struct {
 //
 // 如果验证通过，返回 IdentityPayload。如果验证不通过，返回 error。
 func (v *TokenVerifier) VerifyIdentityToken(tokenString string) (*IdentityPayload, error) {
-	token, err := v.parseToken(tokenString, &identityClaims{})
+	return v.VerifyIdentityTokenContext(context.Background(), tokenString)
+}
+
+// VerifyIdentityTokenContext 和 VerifyIdentityToken 相同，但是可以指定 context，用于查询 RevocationStore 等外部存储。
+func (v *TokenVerifier) VerifyIdentityTokenContext(ctx context.Context, tokenString string) (*IdentityPayload, error) {
+	claims, payload, err := v.parseIdentityToken(tokenString)
 	if err != nil {
 		return nil, err
 	}
+	if err := v.checkTokenAge(&claims.RegisteredClaims); err != nil {
+		return nil, err
+	}
+	if err := v.checkIdentityPolicies(payload); err != nil {
+		return nil, err
+	}
+	if err := v.checkRevocation(ctx, claims); err != nil {
+		return nil, err
+	}
+	if err := v.checkSession(ctx, payload); err != nil {
+		return nil, err
+	}
+	// 一次性使用的校验放在最后，以免其他校验失败时 Token 已被标记为使用过，导致无法重试。
+	if err := v.checkSingleUse(ctx, tokenString, claims); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// parseIdentityToken 解析并验证 Identity Token，解密 weixin_session_key，并构造 IdentityPayload。
+// 如果开启了 Token 缓存，则优先从缓存中获取结果。
+func (v *TokenVerifier) parseIdentityToken(tokenString string) (*identityClaims, *IdentityPayload, error) {
+	if claims, payload, ok := v.cache.get(tokenString, v.clock.Now()); ok {
+		return claims, payload, nil
+	}
+	token, matched, err := v.parseToken(tokenString, &identityClaims{})
+	if err != nil {
+		return nil, nil, err
+	}
 	claims := token.Claims.(*identityClaims)
 	if claims.Scope != identityTokenScope {
-		return nil, fmt.Errorf("invalid scope: %s", claims.Scope)
+		return nil, nil, fmt.Errorf("invalid scope: %s", claims.Scope)
 	}
 	var weixinSessionKey string
 	if claims.WeixinSessionKey != "" {
-		decrypted, err := decryptAESGCM(v.key, claims.WeixinSessionKey)
+		decrypted, err := v.decrypt(claims.WeixinSessionKey, matched)
 		if err != nil {
-			return nil, fmt.Errorf("error decrypting weixin_session_key: %w", err)
+			return nil, nil, fmt.Errorf("error decrypting weixin_session_key: %w", err)
 		}
 		weixinSessionKey = decrypted
 	}
-	return &IdentityPayload{
+	payload := &IdentityPayload{
 		ComboId:          claims.Subject,
 		IdP:              IdP(claims.IdP),
 		ExternalId:       claims.ExternalId,
@@ -160,14 +232,21 @@ func (v *TokenVerifier) VerifyIdentityToken(tokenString string) (*IdentityPayloa
 		Variant:          claims.Variant,
 		Age:              claims.Age,
 		RegTime:          claims.RegTime,
-	}, nil
+		tokenMetadata:    newTokenMetadata(&claims.RegisteredClaims),
+	}
+	payload.extra, err = v.extraClaims(tokenString, identityClaimNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	v.cache.add(tokenString, claims, payload, claims.ExpiresAt.Add(v.leeway))
+	return claims, payload, nil
 }
 
 // VerifyAdToken 对 AdToken 进行验证。
 //
 // 如果验证通过，返回 AdPayload。如果验证不通过，返回 error。
 func (v *TokenVerifier) VerifyAdToken(tokenString string) (*AdPayload, error) {
-	token, err := v.parseToken(tokenString, &adClaims{})
+	token, _, err := v.parseToken(tokenString, &adClaims{})
 	if err != nil {
 		return nil, err
 	}
@@ -175,80 +254,91 @@ func (v *TokenVerifier) VerifyAdToken(tokenString string) (*AdPayload, error) {
 	if claims.Scope != adTokenScope {
 		return nil, fmt.Errorf("invalid scope: %s", claims.Scope)
 	}
-	return &AdPayload{
-		ComboId:      claims.Subject,
-		PlacementId:  claims.PlacementId,
-		ImpressionId: claims.ImpressionId,
-	}, nil
+	if err := v.checkTokenAge(&claims.RegisteredClaims); err != nil {
+		return nil, err
+	}
+	extra, err := v.extraClaims(tokenString, adClaimNames)
+	if err != nil {
+		return nil, err
+	}
+	payload := &AdPayload{
+		ComboId:       claims.Subject,
+		PlacementId:   claims.PlacementId,
+		ImpressionId:  claims.ImpressionId,
+		tokenMetadata: newTokenMetadata(&claims.RegisteredClaims),
+	}
+	payload.extra = extra
+	return payload, nil
 }
 
-func (v *TokenVerifier) parseToken(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+// parseToken 解析并验证 Token，同时返回验证 Token 签名时匹配的密钥。
+func (v *TokenVerifier) parseToken(tokenString string, claims jwt.Claims) (*jwt.Token, keyEntry, error) {
 	token, err := v.parser.ParseWithClaims(tokenString, claims, v.keyFunc)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing token: %w", err)
+		return nil, keyEntry{}, fmt.Errorf("error parsing token: %w", err)
 	}
 	if !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+		return nil, keyEntry{}, fmt.Errorf("invalid token")
 	}
-	return token, nil
+	// 签名已经验证通过，这里只是为了找出匹配的密钥。HMAC 的计算成本很低，重复计算一次可以接受。
+	signingString := tokenString[:strings.LastIndexByte(tokenString, '.')]
+	kid, _ := token.Header["kid"].(string)
+	for _, entry := range v.keys.candidates(kid) {
+		if token.Method.Verify(signingString, token.Signature, []byte(entry.key)) == nil {
+			v.keys.matched(KeyUsage_Token, entry)
+			return token, entry, nil
+		}
+	}
+	return nil, keyEntry{}, fmt.Errorf("invalid token")
 }
 
 func (v *TokenVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
 	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 	}
-	return []byte(v.key), nil
+	kid, _ := token.Header["kid"].(string)
+	entries := v.keys.candidates(kid)
+	switch len(entries) {
+	case 0:
+		return nil, fmt.Errorf("unknown key id: %s", kid)
+	case 1:
+		return []byte(entries[0].key), nil
+	}
+	keySet := jwt.VerificationKeySet{}
+	for _, entry := range entries {
+		keySet.Keys = append(keySet.Keys, []byte(entry.key))
+	}
+	return keySet, nil
 }
 
-// deriveAESKey 从 SecretKey 派生 AES-256 密钥（32 字节）。
-// 使用 SHA-256 哈希，输出恰好为 32 字节。
-func deriveAESKey(sk SecretKey) []byte {
-	hash := sha256.Sum256(sk)
-	return hash[:]
+// decrypt 解密 Token 中的加密字段。优先使用验证 Token 签名时匹配的密钥，然后依次尝试其他有效的密钥。
+func (v *TokenVerifier) decrypt(encoded string, matched keyEntry) (string, error) {
+	plaintext, err := decryptAESGCM(matched.key, encoded)
+	if err == nil {
+		v.keys.matched(KeyUsage_Decryption, matched)
+		return plaintext, nil
+	}
+	for _, entry := range v.keys.keys {
+		if entry.id == matched.id && string(entry.key) == string(matched.key) {
+			continue
+		}
+		if plaintext, e := decryptAESGCM(entry.key, encoded); e == nil {
+			v.keys.matched(KeyUsage_Decryption, entry)
+			return plaintext, nil
+		}
+	}
+	return "", err
 }
 
 // decryptAESGCM 使用 AES-256-GCM 解密 base64 编码的密文。
 // 密文格式为 base64(nonce || ciphertext)，其中 nonce 为 12 字节。
 func decryptAESGCM(sk SecretKey, encoded string) (string, error) {
-	data, err := base64.StdEncoding.DecodeString(encoded)
-	if err != nil {
-		return "", fmt.Errorf("base64 decode error: %w", err)
-	}
-	key := deriveAESKey(sk)
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", fmt.Errorf("aes cipher error: %w", err)
-	}
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", fmt.Errorf("gcm error: %w", err)
-	}
-	nonceSize := gcm.NonceSize()
-	if len(data) < nonceSize {
-		return "", fmt.Errorf("ciphertext too short")
-	}
-	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return "", fmt.Errorf("gcm decrypt error: %w", err)
-	}
-	return string(plaintext), nil
+	return aesgcm.Decrypt(sk, encoded)
 }
 
 // encryptAESGCM 使用 AES-256-GCM 加密明文，返回 base64(nonce || ciphertext)。
 func encryptAESGCM(sk SecretKey, plaintext string, nonce []byte) (string, error) {
-	key := deriveAESKey(sk)
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", fmt.Errorf("aes cipher error: %w", err)
-	}
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", fmt.Errorf("gcm error: %w", err)
-	}
-	ciphertext := gcm.Seal(nil, nonce, []byte(plaintext), nil)
-	result := append(nonce, ciphertext...)
-	return base64.StdEncoding.EncodeToString(result), nil
+	return aesgcm.Encrypt(sk, plaintext, nonce)
 }
 
 func init() {
@@ -0,0 +1,148 @@
+package combo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// VerifierOption 是函数式风格的的可选项，用于创建 TokenVerifier。
+type VerifierOption func(*TokenVerifier)
+
+// WithLeeway 用于指定验证 Token 的 exp、nbf、iat 时允许的时间误差。
+//
+// 如果不指定，则不允许任何误差。
+func WithLeeway(leeway time.Duration) VerifierOption {
+	return func(v *TokenVerifier) {
+		v.leeway = leeway
+	}
+}
+
+// WithMaxTokenAge 用于指定 Token 的最大年龄，即 Token 的签发时间 (iat) 距今不能超过 maxAge。
+//
+// 指定后，缺少 iat 的 Token，以及 iat 晚于当前时间（超出 leeway 误差）的 Token 也会被拒绝。
+// 超出最大年龄的 Token 会返回 ErrTokenTooOld。
+func WithMaxTokenAge(maxAge time.Duration) VerifierOption {
+	return func(v *TokenVerifier) {
+		v.maxAge = maxAge
+	}
+}
+
+// WithAllowedIdPs 用于指定允许的 IdP。IdP 不在列表中的 Identity Token 会返回 ErrIdPNotAllowed。
+func WithAllowedIdPs(idps ...IdP) VerifierOption {
+	return func(v *TokenVerifier) {
+		v.allowedIdPs = toSet(idps)
+	}
+}
+
+// WithAllowedDistros 用于指定允许的 Distro。Distro 不在列表中的 Identity Token 会返回 ErrDistroNotAllowed。
+func WithAllowedDistros(distros ...string) VerifierOption {
+	return func(v *TokenVerifier) {
+		v.allowedDistros = toSet(distros)
+	}
+}
+
+// WithAllowedVariants 用于指定允许的 Variant。Variant 不在列表中的 Identity Token 会返回 ErrVariantNotAllowed。
+//
+// 注意：当客户端不是分包时 Variant 为空字符串，如果需要允许非分包的客户端，列表中应当包含空字符串。
+func WithAllowedVariants(variants ...string) VerifierOption {
+	return func(v *TokenVerifier) {
+		v.allowedVariants = toSet(variants)
+	}
+}
+
+// WithIdentityPolicy 用于指定自定义的 Identity Token 验证策略。
+//
+// policy 会在 Identity Token 通过其他验证之后被调用，如果返回 error，则 VerifyIdentityToken 返回该 error。
+// 例如，禁止特定 Distro 的游客登录：
+//
+//	combo.WithIdentityPolicy(func(p *combo.IdentityPayload) error {
+//	    if p.IdP == combo.IdP_Guest && p.Distro == "official" {
+//	        return errors.New("guest login is not allowed")
+//	    }
+//	    return nil
+//	})
+func WithIdentityPolicy(policy func(*IdentityPayload) error) VerifierOption {
+	return func(v *TokenVerifier) {
+		v.identityPolicies = append(v.identityPolicies, policy)
+	}
+}
+
+var (
+	// ErrTokenTooOld 表示 Token 的签发时间超出了 WithMaxTokenAge 指定的最大年龄。
+	ErrTokenTooOld = errors.New("token is too old")
+
+	// ErrIdPNotAllowed 表示 Identity Token 的 IdP 不在 WithAllowedIdPs 指定的列表中。
+	ErrIdPNotAllowed = errors.New("idp is not allowed")
+
+	// ErrDistroNotAllowed 表示 Identity Token 的 Distro 不在 WithAllowedDistros 指定的列表中。
+	ErrDistroNotAllowed = errors.New("distro is not allowed")
+
+	// ErrVariantNotAllowed 表示 Identity Token 的 Variant 不在 WithAllowedVariants 指定的列表中。
+	ErrVariantNotAllowed = errors.New("variant is not allowed")
+)
+
+// TokenPolicyError 表示 Token 的签名和声明都是有效的，但是不满足 TokenVerifier 的验证策略。
+//
+// 游戏侧可使用 errors.Is 判断具体违反了哪个策略，例如 errors.Is(err, combo.ErrIdPNotAllowed)。
+type TokenPolicyError struct {
+	// 违反的策略，取值为 ErrTokenTooOld、ErrIdPNotAllowed、ErrDistroNotAllowed、ErrVariantNotAllowed 之一。
+	Err error
+
+	// 违反策略的值，例如 IdP 的值。
+	Value string
+}
+
+// Error implements error.
+func (e *TokenPolicyError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Err, e.Value)
+}
+
+// Unwrap 返回违反的策略对应的错误。
+func (e *TokenPolicyError) Unwrap() error {
+	return e.Err
+}
+
+// checkTokenAge 验证 Token 的签发时间是否超出了最大年龄。
+func (v *TokenVerifier) checkTokenAge(claims *jwt.RegisteredClaims) error {
+	if v.maxAge <= 0 {
+		return nil
+	}
+	if claims.IssuedAt == nil {
+		return &TokenPolicyError{Err: ErrTokenTooOld, Value: "missing iat"}
+	}
+	age := v.clock.Now().Sub(claims.IssuedAt.Time)
+	if age > v.maxAge+v.leeway {
+		return &TokenPolicyError{Err: ErrTokenTooOld, Value: age.String()}
+	}
+	return nil
+}
+
+// checkIdentityPolicies 验证 IdentityPayload 是否满足所有的验证策略。
+func (v *TokenVerifier) checkIdentityPolicies(payload *IdentityPayload) error {
+	if v.allowedIdPs != nil && !v.allowedIdPs[payload.IdP] {
+		return &TokenPolicyError{Err: ErrIdPNotAllowed, Value: string(payload.IdP)}
+	}
+	if v.allowedDistros != nil && !v.allowedDistros[payload.Distro] {
+		return &TokenPolicyError{Err: ErrDistroNotAllowed, Value: payload.Distro}
+	}
+	if v.allowedVariants != nil && !v.allowedVariants[payload.Variant] {
+		return &TokenPolicyError{Err: ErrVariantNotAllowed, Value: payload.Variant}
+	}
+	for _, policy := range v.identityPolicies {
+		if err := policy(payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toSet[T comparable](values []T) map[T]bool {
+	set := make(map[T]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
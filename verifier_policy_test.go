@@ -0,0 +1,129 @@
+package combo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestIdentityClaims(issuedAt time.Time) *identityClaims {
+	return &identityClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(testEndpoint),
+			Subject:   "combo_123",
+			Audience:  jwt.ClaimStrings{string(testGameId)},
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+		},
+		Scope:   "auth",
+		IdP:     "guest",
+		Distro:  "official",
+		Variant: "",
+	}
+}
+
+func newTestVerifierWithOptions(t *testing.T, now time.Time, options ...VerifierOption) *TokenVerifier {
+	t.Helper()
+	v, err := NewTokenVerifier(newFrozenConfig(now), options...)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	return v
+}
+
+func TestVerifierWithLeeway(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	claims := newTestIdentityClaims(now.Add(-time.Hour))
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(-30 * time.Second))
+	tokenString := signToken(t, claims)
+
+	if _, err := newTestVerifierWithOptions(t, now).VerifyIdentityToken(tokenString); err == nil {
+		t.Fatal("expected error for expired token without leeway")
+	}
+	v := newTestVerifierWithOptions(t, now, WithLeeway(time.Minute))
+	if _, err := v.VerifyIdentityToken(tokenString); err != nil {
+		t.Fatalf("expected expired token to be accepted within leeway: %v", err)
+	}
+}
+
+func TestVerifierWithMaxTokenAge(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	v := newTestVerifierWithOptions(t, now, WithMaxTokenAge(10*time.Minute))
+
+	if _, err := v.VerifyIdentityToken(signToken(t, newTestIdentityClaims(now.Add(-5*time.Minute)))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := v.VerifyIdentityToken(signToken(t, newTestIdentityClaims(now.Add(-20*time.Minute))))
+	var policyErr *TokenPolicyError
+	if !errors.As(err, &policyErr) || !errors.Is(err, ErrTokenTooOld) {
+		t.Fatalf("expected ErrTokenTooOld, got %v", err)
+	}
+
+	claims := newTestIdentityClaims(now)
+	claims.IssuedAt = nil
+	if _, err := v.VerifyIdentityToken(signToken(t, claims)); !errors.Is(err, ErrTokenTooOld) {
+		t.Fatalf("expected ErrTokenTooOld for missing iat, got %v", err)
+	}
+
+	// Tokens issued in the future are rejected once iat is being validated.
+	if _, err := v.VerifyIdentityToken(signToken(t, newTestIdentityClaims(now.Add(5*time.Minute)))); err == nil {
+		t.Fatal("expected error for token issued in the future")
+	}
+}
+
+func TestVerifierAllowlists(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	tokenString := signToken(t, newTestIdentityClaims(now))
+
+	tests := []struct {
+		name    string
+		option  VerifierOption
+		wantErr error
+	}{
+		{"idp allowed", WithAllowedIdPs(IdP_Guest, IdP_Seayoo), nil},
+		{"idp not allowed", WithAllowedIdPs(IdP_Seayoo), ErrIdPNotAllowed},
+		{"distro allowed", WithAllowedDistros("official"), nil},
+		{"distro not allowed", WithAllowedDistros("taptap"), ErrDistroNotAllowed},
+		{"empty variant allowed", WithAllowedVariants(""), nil},
+		{"variant not allowed", WithAllowedVariants("v1"), ErrVariantNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newTestVerifierWithOptions(t, now, tt.option)
+			_, err := v.VerifyIdentityToken(tokenString)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestVerifierWithIdentityPolicy(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	errGuestBlocked := errors.New("guest login is not allowed")
+	v := newTestVerifierWithOptions(t, now, WithIdentityPolicy(func(p *IdentityPayload) error {
+		if p.IdP == IdP_Guest && p.Distro == "official" {
+			return errGuestBlocked
+		}
+		return nil
+	}))
+
+	if _, err := v.VerifyIdentityToken(signToken(t, newTestIdentityClaims(now))); !errors.Is(err, errGuestBlocked) {
+		t.Fatalf("expected guest login to be blocked, got %v", err)
+	}
+
+	claims := newTestIdentityClaims(now)
+	claims.IdP = "seayoo"
+	if _, err := v.VerifyIdentityToken(signToken(t, claims)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
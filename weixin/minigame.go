@@ -0,0 +1,243 @@
+// Package weixin 提供了微信小游戏相关的辅助功能，例如解密开放数据、验证数据签名、计算虚拟支付签名。
+//
+// 这些功能都基于 combo.IdentityPayload 中的 WeixinSessionKey，即用户在微信小游戏登录时获得的会话密钥 session_key。
+package weixin
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	combo "github.com/seayoo-io/combo-sdk-go"
+)
+
+var (
+	// ErrMissingSessionKey 表示 IdentityPayload 中没有 WeixinSessionKey，通常是因为用户不是通过微信小游戏登录的。
+	ErrMissingSessionKey = errors.New("missing weixin session key")
+
+	// ErrInvalidSignature 表示数据签名验证失败。
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrWatermarkMismatch 表示解密后的数据中的水印 appid 和 Config.AppId 不一致。
+	ErrWatermarkMismatch = errors.New("watermark appid mismatch")
+
+	// ErrWatermarkExpired 表示解密后的数据中的水印时间超出了 Config.MaxWatermarkAge。
+	ErrWatermarkExpired = errors.New("watermark expired")
+
+	// ErrMissingAppKey 表示计算 paySig 时没有配置 Config.AppKey。
+	ErrMissingAppKey = errors.New("missing weixin virtual payment app key")
+)
+
+// Config 包含了创建 Minigame 时所必需的配置项。
+type Config struct {
+	// 微信小游戏的 AppID。
+	AppId string
+
+	// 虚拟支付的 AppKey，可选。仅在需要计算 paySig 时使用，参见 Minigame.PaySig。
+	AppKey string
+
+	// 解密后的数据中的水印时间距今的最大时长，可选。如果不指定，则不检查水印时间。
+	MaxWatermarkAge time.Duration
+
+	// 用于获取当前时间，可选。如果不指定，则默认使用 combo.SystemClock。
+	Clock combo.Clock
+}
+
+func (cfg *Config) validate() error {
+	if cfg.AppId == "" {
+		return errors.New("missing required AppId")
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = combo.SystemClock
+	}
+	return nil
+}
+
+// Minigame 提供了微信小游戏开放数据的解密、签名验证和虚拟支付签名功能。
+type Minigame struct {
+	appId           string
+	appKey          string
+	maxWatermarkAge time.Duration
+	clock           combo.Clock
+}
+
+// NewMinigame 创建一个 Minigame。
+func NewMinigame(cfg Config) (*Minigame, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &Minigame{
+		appId:           cfg.AppId,
+		appKey:          cfg.AppKey,
+		maxWatermarkAge: cfg.MaxWatermarkAge,
+		clock:           cfg.Clock,
+	}, nil
+}
+
+// Watermark 是微信开放数据中的水印，用于校验数据的归属和时效性。
+type Watermark struct {
+	// 数据所属的小游戏 AppID。
+	AppId string `json:"appid"`
+
+	// 数据生成的时间，Unix timestamp in seconds。
+	Timestamp int64 `json:"timestamp"`
+}
+
+// UserInfo 是 wx.getUserInfo 返回的 encryptedData 解密后的用户信息。
+type UserInfo struct {
+	OpenId    string    `json:"openId"`
+	UnionId   string    `json:"unionId"`
+	NickName  string    `json:"nickName"`
+	Gender    int       `json:"gender"`
+	City      string    `json:"city"`
+	Province  string    `json:"province"`
+	Country   string    `json:"country"`
+	AvatarUrl string    `json:"avatarUrl"`
+	Watermark Watermark `json:"watermark"`
+}
+
+// PhoneNumber 是手机号授权返回的 encryptedData 解密后的手机号信息。
+type PhoneNumber struct {
+	// 用户绑定的手机号，国外手机号会有区号。
+	PhoneNumber string `json:"phoneNumber"`
+
+	// 没有区号的手机号。
+	PurePhoneNumber string `json:"purePhoneNumber"`
+
+	// 区号。
+	CountryCode string    `json:"countryCode"`
+	Watermark   Watermark `json:"watermark"`
+}
+
+// DecryptData 使用 identity 中的 session_key 解密微信开放数据 encryptedData，并将解密后的 JSON 解码到 dst 中。
+//
+// dst 通常是 *UserInfo、*PhoneNumber，也可以是游戏侧自定义的结构体。
+// 解密后会检查水印：appid 必须和 Config.AppId 一致；如果指定了 Config.MaxWatermarkAge，水印时间也不能超出范围。
+func (m *Minigame) DecryptData(identity *combo.IdentityPayload, encryptedData, iv string, dst any) error {
+	sessionKey, err := sessionKeyOf(identity)
+	if err != nil {
+		return err
+	}
+	plaintext, err := Decrypt(sessionKey, encryptedData, iv)
+	if err != nil {
+		return err
+	}
+	var envelope struct {
+		Watermark *Watermark `json:"watermark"`
+	}
+	if err := json.Unmarshal(plaintext, &envelope); err != nil {
+		return fmt.Errorf("error decoding decrypted data: %w", err)
+	}
+	if err := m.checkWatermark(envelope.Watermark); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(plaintext, dst); err != nil {
+		return fmt.Errorf("error decoding decrypted data: %w", err)
+	}
+	return nil
+}
+
+// DecryptUserInfo 解密 wx.getUserInfo 返回的 encryptedData。参见 DecryptData。
+func (m *Minigame) DecryptUserInfo(identity *combo.IdentityPayload, encryptedData, iv string) (*UserInfo, error) {
+	var info UserInfo
+	if err := m.DecryptData(identity, encryptedData, iv, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// DecryptPhoneNumber 解密手机号授权返回的 encryptedData。参见 DecryptData。
+func (m *Minigame) DecryptPhoneNumber(identity *combo.IdentityPayload, encryptedData, iv string) (*PhoneNumber, error) {
+	var phone PhoneNumber
+	if err := m.DecryptData(identity, encryptedData, iv, &phone); err != nil {
+		return nil, err
+	}
+	return &phone, nil
+}
+
+// VerifyRawData 验证 wx.getUserInfo 返回的 rawData 的签名，即 signature = sha1(rawData + session_key)。
+func (m *Minigame) VerifyRawData(identity *combo.IdentityPayload, rawData, signature string) error {
+	sessionKey, err := sessionKeyOf(identity)
+	if err != nil {
+		return err
+	}
+	sum := sha1.Sum([]byte(rawData + sessionKey))
+	return compareSignature(hex.EncodeToString(sum[:]), signature)
+}
+
+func (m *Minigame) checkWatermark(w *Watermark) error {
+	if w == nil {
+		return fmt.Errorf("%w: missing watermark", ErrWatermarkMismatch)
+	}
+	if w.AppId != m.appId {
+		return fmt.Errorf("%w: %s", ErrWatermarkMismatch, w.AppId)
+	}
+	if m.maxWatermarkAge > 0 {
+		age := m.clock.Now().Sub(time.Unix(w.Timestamp, 0))
+		if age > m.maxWatermarkAge {
+			return fmt.Errorf("%w: %s", ErrWatermarkExpired, age)
+		}
+	}
+	return nil
+}
+
+func sessionKeyOf(identity *combo.IdentityPayload) (string, error) {
+	if identity == nil || identity.WeixinSessionKey == "" {
+		return "", ErrMissingSessionKey
+	}
+	return identity.WeixinSessionKey, nil
+}
+
+// Decrypt 使用 AES-128-CBC 解密微信开放数据。sessionKey、encryptedData 和 iv 都是 Base64 编码的字符串。
+// sessionKey 解码后必须是 16 字节。
+//
+// Decrypt 只负责解密，不检查水印。通常应当使用 Minigame.DecryptData。
+func Decrypt(sessionKey, encryptedData, iv string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(sessionKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding session key: %w", err)
+	}
+	// aes.NewCipher 也接受 24 和 32 字节的密钥，但微信的 session_key 总是 AES-128 密钥。
+	if len(key) != 16 {
+		return nil, fmt.Errorf("invalid session key length: %d", len(key))
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encryptedData)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding encrypted data: %w", err)
+	}
+	ivBytes, err := base64.StdEncoding.DecodeString(iv)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding iv: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ivBytes) != block.BlockSize() {
+		return nil, fmt.Errorf("invalid iv length: %d", len(ivBytes))
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("invalid encrypted data length: %d", len(ciphertext))
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, ivBytes).CryptBlocks(plaintext, ciphertext)
+	return pkcs7Unpad(plaintext, block.BlockSize())
+}
+
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	n := int(data[len(data)-1])
+	if n == 0 || n > blockSize || n > len(data) {
+		return nil, errors.New("invalid padding")
+	}
+	if !bytes.Equal(data[len(data)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, errors.New("invalid padding")
+	}
+	return data[:len(data)-n], nil
+}
@@ -0,0 +1,165 @@
+package weixin
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	combo "github.com/seayoo-io/combo-sdk-go"
+)
+
+// 微信官方文档中的示例数据。
+const (
+	demoAppId         = "wx4f4bc4dec97d474b"
+	demoSessionKey    = "tiihtNczf5v6AKRyjwEUhQ=="
+	demoIv            = "r7BXXKkLb8qrSNn05n0qiA=="
+	demoEncryptedData = "CiyLU1Aw2KjvrjMdj8YKliAjtP4gsMZMQmRzooG2xrDcvSnxIMXFufNstNGTyaGS9uT5geRa0W4oTOb1WT7fJlAC+oNPdbB+3hVbJSRgv+4lGOETKUQz6OYStslQ142dNCuabNPGBzlooOmB231qMM85d2/fV6ChevvXvQP8Hkue1poOFtnEtpyxVLW1zAo6/1Xx1COxFvrc2d7UL/lmHInNlxuacJXwu0fjpXfz/YqYzBIBzD6WUfTIF9GRHpOn/Hz7saL8xz+W//FRAUid1OksQaQx4CMs8LOddcQhULW4ucetDf96JcR3g0gfRK4PC7E/r7Z6xNrXd2UIeorGj5Ef7b1pJAYB6Y5anaHqZ9J6nKEBvB4DnNLIVWSgARns/8wR2SiRS7MNACwTyrGvt9ts8p12PKFdlqYTopNHR1Vf7XjfhQlVsAJdNiKdYmYVoKlaRv85IfVunYzO0IKXsyl7JCUjCpoG20f0a04COwfneQAGGwd5oa+T8yO5hzuyDb/XcxxmK01EpqOyuxINew=="
+)
+
+func newTestMinigame(t *testing.T, cfg Config) *Minigame {
+	t.Helper()
+	if cfg.AppId == "" {
+		cfg.AppId = demoAppId
+	}
+	m, err := NewMinigame(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// encrypt 使用 AES-128-CBC 加密 plaintext，返回 Base64 编码的 encryptedData 和 iv。
+func encrypt(t *testing.T, sessionKey string, plaintext []byte) (string, string) {
+	t.Helper()
+	key, err := base64.StdEncoding.DecodeString(sessionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		t.Fatal(err)
+	}
+	n := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(n)}, n)...)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+	return base64.StdEncoding.EncodeToString(ciphertext), base64.StdEncoding.EncodeToString(iv)
+}
+
+func TestDecryptUserInfo(t *testing.T) {
+	m := newTestMinigame(t, Config{})
+	info, err := m.DecryptUserInfo(&combo.IdentityPayload{WeixinSessionKey: demoSessionKey}, demoEncryptedData, demoIv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.OpenId != "oGZUI0egBJY1zhBYw2KhdUfwVJJE" || info.UnionId != "ocMvos6NjeKLIBqg5Mr9QjxrP1FA" || info.NickName != "Band" {
+		t.Fatalf("unexpected user info: %+v", info)
+	}
+	if info.Watermark.AppId != demoAppId || info.Watermark.Timestamp != 1477314187 {
+		t.Fatalf("unexpected watermark: %+v", info.Watermark)
+	}
+}
+
+func TestDecryptPhoneNumber(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := newTestMinigame(t, Config{
+		MaxWatermarkAge: 5 * time.Minute,
+		Clock:           combo.ClockFunc(func() time.Time { return now }),
+	})
+	identity := &combo.IdentityPayload{WeixinSessionKey: demoSessionKey}
+	data, iv := encrypt(t, demoSessionKey, []byte(`{"phoneNumber":"13580006666","purePhoneNumber":"13580006666","countryCode":"86","watermark":{"appid":"wx4f4bc4dec97d474b","timestamp":1700000000}}`))
+
+	phone, err := m.DecryptPhoneNumber(identity, data, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phone.PhoneNumber != "13580006666" || phone.CountryCode != "86" {
+		t.Fatalf("unexpected phone number: %+v", phone)
+	}
+
+	now = now.Add(6 * time.Minute)
+	if _, err := m.DecryptPhoneNumber(identity, data, iv); !errors.Is(err, ErrWatermarkExpired) {
+		t.Fatalf("expected ErrWatermarkExpired, got %v", err)
+	}
+}
+
+func TestDecryptDataWatermarkMismatch(t *testing.T) {
+	m := newTestMinigame(t, Config{AppId: "wx_other"})
+	identity := &combo.IdentityPayload{WeixinSessionKey: demoSessionKey}
+	if _, err := m.DecryptUserInfo(identity, demoEncryptedData, demoIv); !errors.Is(err, ErrWatermarkMismatch) {
+		t.Fatalf("expected ErrWatermarkMismatch, got %v", err)
+	}
+
+	data, iv := encrypt(t, demoSessionKey, []byte(`{"openId":"o_123"}`))
+	if _, err := m.DecryptUserInfo(identity, data, iv); !errors.Is(err, ErrWatermarkMismatch) {
+		t.Fatalf("expected ErrWatermarkMismatch for missing watermark, got %v", err)
+	}
+}
+
+func TestDecryptDataMissingSessionKey(t *testing.T) {
+	m := newTestMinigame(t, Config{})
+	if _, err := m.DecryptUserInfo(&combo.IdentityPayload{}, demoEncryptedData, demoIv); !errors.Is(err, ErrMissingSessionKey) {
+		t.Fatalf("expected ErrMissingSessionKey, got %v", err)
+	}
+	if err := m.VerifyRawData(nil, "{}", ""); !errors.Is(err, ErrMissingSessionKey) {
+		t.Fatalf("expected ErrMissingSessionKey, got %v", err)
+	}
+}
+
+func TestDecryptInvalidInput(t *testing.T) {
+	if _, err := Decrypt(demoSessionKey, demoEncryptedData, "AAAA"); err == nil {
+		t.Fatal("expected error for invalid iv length")
+	}
+	if _, err := Decrypt(demoSessionKey, "AAAA", demoIv); err == nil {
+		t.Fatal("expected error for invalid encrypted data length")
+	}
+	if _, err := Decrypt(demoSessionKey, demoEncryptedData, "not base64"); err == nil {
+		t.Fatal("expected error for malformed iv")
+	}
+	// 使用错误的 session_key 解密，填充几乎必然无效。
+	if _, err := Decrypt("AAAAAAAAAAAAAAAAAAAAAA==", demoEncryptedData, demoIv); err == nil {
+		t.Fatal("expected error for wrong session key")
+	}
+}
+
+func TestDecryptInvalidSessionKeyLength(t *testing.T) {
+	for _, n := range []int{8, 24, 32} {
+		sessionKey := base64.StdEncoding.EncodeToString(make([]byte, n))
+		_, err := Decrypt(sessionKey, demoEncryptedData, demoIv)
+		if err == nil || !strings.Contains(err.Error(), "invalid session key length") {
+			t.Fatalf("expected invalid session key length error for %d-byte key, got %v", n, err)
+		}
+	}
+}
+
+func TestVerifyRawData(t *testing.T) {
+	m := newTestMinigame(t, Config{})
+	identity := &combo.IdentityPayload{WeixinSessionKey: demoSessionKey}
+	rawData := `{"nickName":"Band","gender":1}`
+	sum := sha1.Sum([]byte(rawData + demoSessionKey))
+
+	if err := m.VerifyRawData(identity, rawData, hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+	if err := m.VerifyRawData(identity, rawData+" ", hex.EncodeToString(sum[:])); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestNewMinigameMissingAppId(t *testing.T) {
+	if _, err := NewMinigame(Config{}); err == nil {
+		t.Fatal("expected error for missing AppId")
+	}
+}
@@ -0,0 +1,190 @@
+package weixin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	combo "github.com/seayoo-io/combo-sdk-go"
+)
+
+// 签名算法，作为服务端 API 请求的 sig_method 参数。
+const SigMethodHmacSha256 = "hmac_sha256"
+
+// 客户端调用 wx.requestVirtualPayment 时，计算 paySig 所使用的 uri。
+const requestVirtualPaymentUri = "requestVirtualPayment"
+
+// VirtualPaymentMode 是 wx.requestVirtualPayment 的支付类型 mode。
+type VirtualPaymentMode string
+
+const (
+	// 道具直购。
+	VirtualPaymentMode_ShortSeriesGoods VirtualPaymentMode = "short_series_goods"
+
+	// 代币充值。
+	VirtualPaymentMode_ShortSeriesCoin VirtualPaymentMode = "short_series_coin"
+)
+
+// VirtualPaymentRequest 是 wx.requestVirtualPayment 的 signData 参数。
+//
+// 各字段的含义参见微信小游戏虚拟支付文档。
+type VirtualPaymentRequest struct {
+	// 支付类型。不会序列化到 signData 中。
+	Mode VirtualPaymentMode `json:"-"`
+
+	// 在米大师侧申请的应用 id。
+	OfferId string `json:"offerId"`
+
+	// 购买数量。
+	BuyQuantity int `json:"buyQuantity"`
+
+	// 环境配置。0 表示现网环境，1 表示沙箱环境。
+	Env int `json:"env"`
+
+	// 币种，目前仅支持 "CNY"。
+	CurrencyType string `json:"currencyType"`
+
+	// 申请接入时的平台，目前仅支持 "android"。
+	Platform string `json:"platform,omitempty"`
+
+	// 道具 ID，仅道具直购时需要。
+	ProductId string `json:"productId,omitempty"`
+
+	// 道具单价，单位为分，仅道具直购时需要。
+	GoodsPrice int `json:"goodsPrice,omitempty"`
+
+	// 业务订单号，每个订单号只能使用一次。
+	OutTradeNo string `json:"outTradeNo"`
+
+	// 透传数据，发货通知时会透传给开发者。
+	Attach string `json:"attach,omitempty"`
+}
+
+// VirtualPaymentParams 是调用 wx.requestVirtualPayment 所需的签名参数，由游戏服务端生成后下发给客户端。
+type VirtualPaymentParams struct {
+	Mode      VirtualPaymentMode `json:"mode"`
+	SignData  string             `json:"signData"`
+	PaySig    string             `json:"paySig"`
+	Signature string             `json:"signature"`
+}
+
+// SignVirtualPayment 为 wx.requestVirtualPayment 生成签名参数。
+//
+// signData 是 req 序列化后的 JSON，客户端必须原样传递，不能重新序列化，否则签名会失效。
+// 需要配置 Config.AppKey。
+func (m *Minigame) SignVirtualPayment(identity *combo.IdentityPayload, req *VirtualPaymentRequest) (*VirtualPaymentParams, error) {
+	signData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding sign data: %w", err)
+	}
+	signature, err := m.UserSignature(identity, signData)
+	if err != nil {
+		return nil, err
+	}
+	paySig, err := m.PaySig(requestVirtualPaymentUri, signData)
+	if err != nil {
+		return nil, err
+	}
+	return &VirtualPaymentParams{
+		Mode:      req.Mode,
+		SignData:  string(signData),
+		PaySig:    paySig,
+		Signature: signature,
+	}, nil
+}
+
+// SignedRequest 是签名后的虚拟支付服务端 API 请求。
+type SignedRequest struct {
+	// 请求体，必须原样作为 POST body 发送。
+	Body []byte
+
+	// 用户态签名。请求中不包含用户信息时为空。
+	Signature string
+
+	// 支付签名。
+	PaySig string
+}
+
+// Query 返回签名相关的 URL query 参数，调用方还需要自行添加 access_token。
+func (r *SignedRequest) Query() url.Values {
+	q := url.Values{}
+	if r.Signature != "" {
+		q.Set("signature", r.Signature)
+		q.Set("sig_method", SigMethodHmacSha256)
+	}
+	q.Set("pay_sig", r.PaySig)
+	return q
+}
+
+// SignRequest 为虚拟支付服务端 API 请求生成签名。
+//
+// uri 是不包含域名和 query 的请求路径，例如 "/wxa/game/getbalance"。req 会被序列化为 JSON 作为请求体。
+// identity 为 nil 时只计算 paySig，适用于不需要用户态签名的 API。需要配置 Config.AppKey。
+func (m *Minigame) SignRequest(identity *combo.IdentityPayload, uri string, req any) (*SignedRequest, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request body: %w", err)
+	}
+	r := &SignedRequest{Body: body}
+	if identity != nil {
+		if r.Signature, err = m.UserSignature(identity, body); err != nil {
+			return nil, err
+		}
+	}
+	if r.PaySig, err = m.PaySig(uri, body); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// UserSignature 计算用户态签名，即 signature = hex(hmac_sha256(session_key, body))。
+func (m *Minigame) UserSignature(identity *combo.IdentityPayload, body []byte) (string, error) {
+	sessionKey, err := sessionKeyOf(identity)
+	if err != nil {
+		return "", err
+	}
+	return hmacSha256([]byte(sessionKey), body), nil
+}
+
+// PaySig 计算支付签名，即 pay_sig = hex(hmac_sha256(AppKey, uri + "&" + body))。
+func (m *Minigame) PaySig(uri string, body []byte) (string, error) {
+	if m.appKey == "" {
+		return "", ErrMissingAppKey
+	}
+	return hmacSha256([]byte(m.appKey), []byte(uri+"&"+string(body))), nil
+}
+
+// VerifyUserSignature 验证用户态签名。参见 UserSignature。
+func (m *Minigame) VerifyUserSignature(identity *combo.IdentityPayload, body []byte, signature string) error {
+	expected, err := m.UserSignature(identity, body)
+	if err != nil {
+		return err
+	}
+	return compareSignature(expected, signature)
+}
+
+// VerifyPaySig 验证支付签名。参见 PaySig。
+func (m *Minigame) VerifyPaySig(uri string, body []byte, paySig string) error {
+	expected, err := m.PaySig(uri, body)
+	if err != nil {
+		return err
+	}
+	return compareSignature(expected, paySig)
+}
+
+func hmacSha256(key, data []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func compareSignature(expected, actual string) error {
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
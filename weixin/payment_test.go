@@ -0,0 +1,126 @@
+package weixin
+
+import (
+	"errors"
+	"testing"
+
+	combo "github.com/seayoo-io/combo-sdk-go"
+)
+
+// 以下签名由 openssl dgst -sha256 -hmac 独立计算得出。
+const (
+	testAppKey     = "12345"
+	testSessionKey = "9hAb/NEYUlkaMBEsmFgzig=="
+
+	balanceUri       = "/wxa/game/getbalance"
+	balanceBody      = `{"openid":"oUrsfxxxxxxxxxx","offer_id":"12345678","ts":1668136271,"zone_id":"1","env":0,"user_ip":"127.0.0.1"}`
+	balancePaySig    = "32c81a75105e79fd9e0a5fe743c1a65ba4487ac7dd3cf32976364d82d9c61fff"
+	balanceSignature = "cdcae2d037fc32c4410adcb2aad4147e04ef43f3f70ad8a08e98045c1b26974d"
+
+	paymentSignData  = `{"offerId":"123","buyQuantity":1,"env":0,"currencyType":"CNY","platform":"android","productId":"testproductId","goodsPrice":10,"outTradeNo":"xxxxxx","attach":"testdata"}`
+	paymentPaySig    = "1ff5217960cb5be71b5321f3884dbbf055f1b907616408b94352185d8eb9dea1"
+	paymentSignature = "13d2f91ff8034f3fc35c58743724be4abbc02b9e4e09022bd66770ebbae6ce3f"
+)
+
+type getBalanceRequest struct {
+	OpenId  string `json:"openid"`
+	OfferId string `json:"offer_id"`
+	Ts      int64  `json:"ts"`
+	ZoneId  string `json:"zone_id"`
+	Env     int    `json:"env"`
+	UserIp  string `json:"user_ip"`
+}
+
+func TestSignVirtualPayment(t *testing.T) {
+	m := newTestMinigame(t, Config{AppKey: testAppKey})
+	params, err := m.SignVirtualPayment(&combo.IdentityPayload{WeixinSessionKey: testSessionKey}, &VirtualPaymentRequest{
+		Mode:         VirtualPaymentMode_ShortSeriesGoods,
+		OfferId:      "123",
+		BuyQuantity:  1,
+		Env:          0,
+		CurrencyType: "CNY",
+		Platform:     "android",
+		ProductId:    "testproductId",
+		GoodsPrice:   10,
+		OutTradeNo:   "xxxxxx",
+		Attach:       "testdata",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.SignData != paymentSignData {
+		t.Fatalf("unexpected sign data: %s", params.SignData)
+	}
+	if params.PaySig != paymentPaySig || params.Signature != paymentSignature {
+		t.Fatalf("unexpected signatures: %+v", params)
+	}
+	if params.Mode != VirtualPaymentMode_ShortSeriesGoods {
+		t.Fatalf("unexpected mode: %s", params.Mode)
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	m := newTestMinigame(t, Config{AppKey: testAppKey})
+	req := &getBalanceRequest{
+		OpenId:  "oUrsfxxxxxxxxxx",
+		OfferId: "12345678",
+		Ts:      1668136271,
+		ZoneId:  "1",
+		UserIp:  "127.0.0.1",
+	}
+	r, err := m.SignRequest(&combo.IdentityPayload{WeixinSessionKey: testSessionKey}, balanceUri, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r.Body) != balanceBody || r.PaySig != balancePaySig || r.Signature != balanceSignature {
+		t.Fatalf("unexpected signed request: body=%s pay_sig=%s signature=%s", r.Body, r.PaySig, r.Signature)
+	}
+	q := r.Query()
+	if q.Get("pay_sig") != balancePaySig || q.Get("signature") != balanceSignature || q.Get("sig_method") != SigMethodHmacSha256 {
+		t.Fatalf("unexpected query: %v", q)
+	}
+
+	// 不需要用户态签名的 API 只计算 pay_sig。
+	r, err = m.SignRequest(nil, balanceUri, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Signature != "" || r.PaySig != balancePaySig {
+		t.Fatalf("unexpected signed request: %+v", r)
+	}
+	if q := r.Query(); q.Has("signature") || q.Has("sig_method") {
+		t.Fatalf("unexpected query: %v", q)
+	}
+}
+
+func TestVerifyPaymentSignatures(t *testing.T) {
+	m := newTestMinigame(t, Config{AppKey: testAppKey})
+	identity := &combo.IdentityPayload{WeixinSessionKey: testSessionKey}
+
+	if err := m.VerifyPaySig(balanceUri, []byte(balanceBody), balancePaySig); err != nil {
+		t.Fatalf("expected valid pay_sig, got %v", err)
+	}
+	if err := m.VerifyPaySig("/wxa/game/pay", []byte(balanceBody), balancePaySig); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+	if err := m.VerifyUserSignature(identity, []byte(balanceBody), balanceSignature); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+	if err := m.VerifyUserSignature(identity, []byte(balanceBody+" "), balanceSignature); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+	if _, err := m.UserSignature(&combo.IdentityPayload{}, []byte(balanceBody)); !errors.Is(err, ErrMissingSessionKey) {
+		t.Fatalf("expected ErrMissingSessionKey, got %v", err)
+	}
+}
+
+func TestPaySigMissingAppKey(t *testing.T) {
+	m := newTestMinigame(t, Config{})
+	if _, err := m.PaySig(balanceUri, []byte(balanceBody)); !errors.Is(err, ErrMissingAppKey) {
+		t.Fatalf("expected ErrMissingAppKey, got %v", err)
+	}
+	_, err := m.SignVirtualPayment(&combo.IdentityPayload{WeixinSessionKey: testSessionKey}, &VirtualPaymentRequest{})
+	if !errors.Is(err, ErrMissingAppKey) {
+		t.Fatalf("expected ErrMissingAppKey, got %v", err)
+	}
+}